@@ -17,10 +17,16 @@ else
   PROJECT_ROOT="$(dirname "$0")"
 fi
 
-# 2. Tidy & build the Go binary
+# 2. Tidy & build the Go binary (version/commit stamped into internal/buildinfo)
 cd "$PROJECT_ROOT"
 go mod tidy
-go build -o "$PROJECT_ROOT/build/stream-overlay-server" ./cmd/stream-overlay
+PKG=github.com/dtorres47/stream-overlay/internal/buildinfo
+VERSION="$(git describe --tags --always 2>/dev/null || echo dev)"
+COMMIT="$(git rev-parse --short HEAD 2>/dev/null || echo unknown)"
+BUILT_AT="$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+go build \
+  -ldflags "-X $PKG.Version=$VERSION -X $PKG.Commit=$COMMIT -X $PKG.BuiltAt=$BUILT_AT" \
+  -o "$PROJECT_ROOT/build/stream-overlay-server" ./cmd/stream-overlay
 
 # 3. Copy static web assets (entire tree)
 rm -rf "$PROJECT_ROOT/build/stream-overlay-web"
@@ -0,0 +1,966 @@
+// Package client is a typed Go client for the stream-overlay HTTP API, for
+// bots and tools that drive the overlay. The wire format is described by
+// /api/openapi.json.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client talks to a running overlay server.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Token      string // operator bearer token; names the operator on request state changes and is needed for RevealPhone and RecordDonation
+}
+
+// New returns a Client for baseURL (e.g. "http://localhost:3000").
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+// Error is returned for any non-2xx response. Code is one of the API's
+// error codes (bad_request, unauthorized, forbidden, not_found, conflict,
+// validation_failed, rate_limited, internal); Field names the offending parameter when there is one.
+type Error struct {
+	Status     int
+	Code       string
+	Message    string
+	Field      string
+	RetryAfter time.Duration // set on 429 rate_limited responses
+}
+
+func (e *Error) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("overlay api: %d %s (%s): %s", e.Status, e.Code, e.Field, e.Message)
+	}
+	return fmt.Sprintf("overlay api: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Types
+// ─────────────────────────────────────────────────────────────────────────────
+
+type Ability struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	PriceCents int64   `json:"price_cents"`
+	SFXURL     string  `json:"sfx_url"`
+	IconURL    string  `json:"icon_url"`
+	CooldownMs int     `json:"cooldown_ms"`
+	Volume     float64 `json:"volume"`
+}
+
+type Quest struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	PriceCents int64     `json:"price_cents"`
+	IconURL    string    `json:"icon_url"`
+	Target     int       `json:"target"`
+	Triggers   []Trigger `json:"triggers,omitempty"`
+	Overfund   string    `json:"overfund,omitempty"` // "raise" or "repeat"
+
+	Celebration   Celebration `json:"celebration"`
+	RewardAbility string      `json:"reward_ability,omitempty"` // fired when the quest completes
+
+	DurationSeconds int `json:"duration_seconds,omitempty"` // time allowed per run; 0 = no deadline
+}
+
+// Celebration is how the overlay marks a quest's completion.
+type Celebration struct {
+	SoundURL  string `json:"sound_url,omitempty"`
+	Animation string `json:"animation,omitempty"`
+	TTS       string `json:"tts,omitempty"`
+}
+
+// Trigger advances an active quest automatically: On is "ability",
+// "request_completed" or "donation", and ID narrows it to one ability or
+// board.
+type Trigger struct {
+	On        string `json:"on"`
+	ID        string `json:"id,omitempty"`
+	Step      int    `json:"step,omitempty"`
+	PerDollar bool   `json:"per_dollar,omitempty"`
+}
+
+// Board is a kind of call request with its own queue and rules.
+type Board struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	PriceCents    int64  `json:"price_cents"`
+	MaxActive     int    `json:"max_active"` // 0 = no limit
+	PhoneRequired bool   `json:"phone_required"`
+	Style         struct {
+		Color string `json:"color,omitempty"`
+		Icon  string `json:"icon,omitempty"`
+	} `json:"style"`
+}
+
+// BoardSummary is a board with its current queue sizes.
+type BoardSummary struct {
+	Board
+	Pending int `json:"pending"`
+	Active  int `json:"active"`
+}
+
+type Catalog struct {
+	Abilities []Ability `json:"abilities"`
+	Quests    []Quest   `json:"quests"`
+	Boards    []Board   `json:"boards"`
+}
+
+type QuestState struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Target     int    `json:"target"`
+	Progress   int    `json:"progress"`
+	IconURL    string `json:"icon_url"`
+	PriceCents int64  `json:"price_cents"`
+
+	Repeats        int              `json:"repeats,omitempty"` // further runs paid for by overfunding
+	FundedBy       []Contribution   `json:"funded_by,omitempty"`
+	RepeatFundedBy [][]Contribution `json:"repeat_funded_by,omitempty"` // who paid for each further run, next first
+
+	CompletedUnix int64 `json:"completed_unix,omitempty"` // set until the completed run is archived
+
+	DeadlineUnix    int64 `json:"deadline_unix,omitempty"`            // while the run's timer is running
+	PausedRemaining int64 `json:"paused_remaining_seconds,omitempty"` // while timers are paused
+	FailedUnix      int64 `json:"failed_unix,omitempty"`              // the run missed its deadline
+}
+
+// QuestTimers is whether quest timers are paused for a break.
+type QuestTimers struct {
+	Paused     bool  `json:"paused"`
+	PausedUnix int64 `json:"paused_unix,omitempty"`
+}
+
+// CompletedQuest is one finished run of a quest; failed runs have
+// FailedUnix set.
+type CompletedQuest struct {
+	QuestState
+	ArchivedUnix int64 `json:"archived_unix"`
+}
+
+// QuestSession is a stream session and the quests completed during it.
+type QuestSession struct {
+	ID          int              `json:"id"`
+	StartedUnix int64            `json:"started_unix"`
+	Quests      []CompletedQuest `json:"quests"`
+}
+
+// Contribution is one donation toward a crowdfunded quest.
+type Contribution struct {
+	Donor string `json:"donor"`
+	Cents int64  `json:"cents"`
+	Unix  int64  `json:"unix"`
+}
+
+// Funding is the pledges toward a crowdfunded quest's next purchase.
+type Funding struct {
+	ID           string         `json:"id"`
+	Name         string         `json:"name"`
+	GoalCents    int64          `json:"goal_cents"`
+	RaisedCents  int64          `json:"raised_cents"`
+	Contributors []Contribution `json:"contributors"`
+}
+
+// Donation is an entry for the donation ledger. Amount is in dollars;
+// Quest, if set, crowdfunds that quest.
+type Donation struct {
+	Time    time.Time `json:"time"`
+	Donor   string    `json:"donor"`
+	Amount  float64   `json:"amount"`
+	Message string    `json:"message"`
+	Quest   string    `json:"quest,omitempty"`
+}
+
+type TTSItem struct {
+	ID           int       `json:"id"`
+	Text         string    `json:"text"`
+	Voice        string    `json:"voice"`
+	Donor        string    `json:"donor"`
+	AmountCents  int64     `json:"amount_cents"`
+	Msg          string    `json:"msg"`
+	CreatedUnix  int64     `json:"created_unix"`
+	Status       string    `json:"status"`
+	OriginalText string    `json:"original_text,omitempty"`
+	Flags        []TTSFlag `json:"flags,omitempty"`
+	Score        int       `json:"score,omitempty"`
+	RejectReason string    `json:"reject_reason,omitempty"`
+	ApprovedBy   string    `json:"approved_by,omitempty"`
+	Tier         int       `json:"tier"`
+	VoiceWanted  string    `json:"voice_wanted,omitempty"`
+	PlayedUnix   int64     `json:"played_unix,omitempty"`
+	Replays      int       `json:"replays,omitempty"`
+	FinishedUnix int64     `json:"finished_unix,omitempty"`
+}
+
+type TTSFlag struct {
+	Code   string `json:"code"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type ModerationConfig struct {
+	Blocklist   []string `json:"blocklist"`
+	MaxLength   int      `json:"max_length"`
+	MaxRepeat   int      `json:"max_repeat"`
+	MaxEmoji    int      `json:"max_emoji"`
+	StripURLs   bool     `json:"strip_urls"`
+	StripPhones bool     `json:"strip_phones"`
+	RejectScore int      `json:"reject_score"`
+}
+
+// TTSSubmission is the input to SubmitTTS. Only Text is required.
+type TTSSubmission struct {
+	Text        string
+	Voice       string
+	Donor       string
+	AmountCents int64
+	Msg         string
+}
+
+type RequestItem struct {
+	ID          int          `json:"id"`
+	Board       string       `json:"board"`
+	MaskedPhone string       `json:"masked_phone"`
+	PhoneRegion string       `json:"phone_region,omitempty"`
+	Note        string       `json:"note"`
+	Status      string       `json:"status"`
+	CreatedUnix int64        `json:"created_unix"`
+	UpdatedUnix int64        `json:"updated_unix,omitempty"`
+	OutcomeNote string       `json:"outcome_note,omitempty"`
+	History     []Transition `json:"history,omitempty"`
+
+	Pinned  bool `json:"pinned,omitempty"`
+	Flagged bool `json:"flagged,omitempty"`
+
+	// Set on paid boards: donate PriceCents quoting PaymentRef (or as Donor,
+	// if that is the donor's only hold) to move the request from
+	// awaiting_payment to pending.
+	Donor      string `json:"donor,omitempty"`
+	PriceCents int64  `json:"price_cents,omitempty"`
+	PaymentRef string `json:"payment_ref,omitempty"`
+	PaidCents  int64  `json:"paid_cents,omitempty"`
+
+	AutoReason  string `json:"auto_reason,omitempty"` // why it was rejected or merged on submit
+	DuplicateOf int    `json:"duplicate_of,omitempty"`
+	Duplicates  int    `json:"duplicates,omitempty"`
+
+	HasPhone        bool  `json:"has_phone"` // a full number can be revealed
+	PhonePurgedUnix int64 `json:"phone_purged_unix,omitempty"`
+}
+
+// Transition is one recorded state change on a request.
+type Transition struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Unix     int64  `json:"unix"`
+	Operator string `json:"operator"`
+	Note     string `json:"note,omitempty"`
+}
+
+// RequestSubmission is the input to SubmitRequest. Board (an ID or name
+// from RequestBoards) is required, and so is Phone on boards that say so.
+// Phone may carry a +country code; otherwise it is read as local to Region
+// (an ISO country code, defaulting to the server's). Donor names who may
+// pay for a request on a paid board.
+type RequestSubmission struct {
+	Board  string
+	Phone  string
+	Region string
+	Note   string
+	Donor  string
+}
+
+// PublicStatus is a request's progress as its submitter sees it. Position
+// is set while pending; ETASeconds while pending or approved.
+type PublicStatus struct {
+	Status     string `json:"status"`
+	Position   int    `json:"position,omitempty"`
+	ETASeconds *int64 `json:"eta_seconds,omitempty"`
+}
+
+// SubmitReceipt is returned by SubmitRequest. Token is shown only once; keep
+// it to follow the request with RequestStatus or the StatusPage.
+type SubmitReceipt struct {
+	Token      string `json:"token"`
+	StatusPage string `json:"status_page"`
+	PublicStatus
+	PaymentRef string `json:"payment_ref,omitempty"`
+	PriceCents int64  `json:"price_cents,omitempty"`
+}
+
+// PhoneRegion is a country phone numbers may come from.
+type PhoneRegion struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	CountryCode string `json:"country_code"`
+}
+
+type PhoneRegionList struct {
+	Default string        `json:"default"`
+	Regions []PhoneRegion `json:"regions"`
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Catalog & quests
+// ─────────────────────────────────────────────────────────────────────────────
+
+func (c *Client) Catalog(ctx context.Context) (*Catalog, error) {
+	var out Catalog
+	return &out, c.do(ctx, http.MethodGet, "/api/catalog", nil, &out)
+}
+
+// FireAbility plays an ability on the overlay. It fails with Code
+// "rate_limited" while the ability is cooling down.
+func (c *Client) FireAbility(ctx context.Context, id string) (*Ability, error) {
+	var out Ability
+	return &out, c.do(ctx, http.MethodPost, "/api/ability/fire", url.Values{"id": {id}}, &out)
+}
+
+func (c *Client) AddQuest(ctx context.Context, id string) (*QuestState, error) {
+	var out QuestState
+	return &out, c.do(ctx, http.MethodGet, "/api/quest/add", url.Values{"id": {id}}, &out)
+}
+
+func (c *Client) ActiveQuests(ctx context.Context) ([]QuestState, error) {
+	var out []QuestState
+	return out, c.do(ctx, http.MethodGet, "/api/quest/active", nil, &out)
+}
+
+// QuestFunding lists pledges toward crowdfunded quests.
+func (c *Client) QuestFunding(ctx context.Context) ([]Funding, error) {
+	var out []Funding
+	return out, c.do(ctx, http.MethodGet, "/api/quest/funding", nil, &out)
+}
+
+// CompletedQuests lists completed quests by stream session, newest first.
+// A session of 0 lists every session.
+func (c *Client) CompletedQuests(ctx context.Context, session int) ([]QuestSession, error) {
+	var q url.Values
+	if session != 0 {
+		q = url.Values{"session": {strconv.Itoa(session)}}
+	}
+	var out []QuestSession
+	return out, c.do(ctx, http.MethodGet, "/api/quest/completed", q, &out)
+}
+
+// StartQuestSession starts a new stream session for the completed-quest
+// archive.
+func (c *Client) StartQuestSession(ctx context.Context) (*QuestSession, error) {
+	var out QuestSession
+	return &out, c.do(ctx, http.MethodPost, "/api/quest/session", nil, &out)
+}
+
+// QuestTimers reports whether quest timers are paused.
+func (c *Client) QuestTimers(ctx context.Context) (*QuestTimers, error) {
+	var out QuestTimers
+	return &out, c.do(ctx, http.MethodGet, "/api/quest/timers", nil, &out)
+}
+
+// PauseQuestTimers pauses every quest timer for a break, or resumes them.
+func (c *Client) PauseQuestTimers(ctx context.Context, paused bool) (*QuestTimers, error) {
+	var out QuestTimers
+	return &out, c.do(ctx, http.MethodPost, "/api/quest/timers", url.Values{"paused": {strconv.FormatBool(paused)}}, &out)
+}
+
+// RecordDonation appends a donation to the ledger. A donation naming
+// d.Quest funds that quest; otherwise it may pay for a matching held
+// request. Only money neither takes counts toward per-dollar quest
+// triggers. The client's Token must carry
+// the record_donations role.
+func (c *Client) RecordDonation(ctx context.Context, d Donation) (*Donation, error) {
+	var out Donation
+	return &out, c.doJSON(ctx, http.MethodPost, "/api/donations", nil, d, &out)
+}
+
+func (c *Client) IncQuest(ctx context.Context, id string) (*QuestState, error) {
+	var out QuestState
+	return &out, c.do(ctx, http.MethodPost, "/api/quest/inc", url.Values{"id": {id}}, &out)
+}
+
+func (c *Client) ResetQuest(ctx context.Context, id string) (*QuestState, error) {
+	var out QuestState
+	return &out, c.do(ctx, http.MethodPost, "/api/quest/reset", url.Values{"id": {id}}, &out)
+}
+
+func (c *Client) RemoveQuest(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/api/quest/remove", url.Values{"id": {id}}, nil)
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// TTS
+// ─────────────────────────────────────────────────────────────────────────────
+
+// TTSSubmitResult is the queued item plus the donor's remaining allowance.
+type TTSSubmitResult struct {
+	TTSItem
+	CharLimit       int `json:"char_limit"`
+	CharsRemaining  int `json:"chars_remaining"`
+	CooldownSeconds int `json:"cooldown_seconds"`
+}
+
+// SubmitTTS queues a message. A donor still cooling down gets an *Error
+// with Code "rate_limited" and RetryAfter set.
+func (c *Client) SubmitTTS(ctx context.Context, s TTSSubmission) (*TTSSubmitResult, error) {
+	q := url.Values{"text": {s.Text}}
+	setIf(q, "voice", s.Voice)
+	setIf(q, "donor", s.Donor)
+	setIf(q, "msg", s.Msg)
+	if s.AmountCents > 0 {
+		q.Set("amount_cents", strconv.FormatInt(s.AmountCents, 10))
+	}
+	var out TTSSubmitResult
+	return &out, c.do(ctx, http.MethodGet, "/api/tts/submit", q, &out)
+}
+
+func (c *Client) TTSQueue(ctx context.Context) ([]TTSItem, error) {
+	var out []TTSItem
+	return out, c.do(ctx, http.MethodGet, "/api/tts/queue", nil, &out)
+}
+
+// MoveTTS puts a pending item at position to (0-based) among pending items
+// and returns the reordered pending list.
+func (c *Client) MoveTTS(ctx context.Context, id, to int) ([]TTSItem, error) {
+	var out []TTSItem
+	q := idQuery(id)
+	q.Set("to", strconv.Itoa(to))
+	return out, c.do(ctx, http.MethodPost, "/api/tts/move", q, &out)
+}
+
+type TTSQueueConfig struct {
+	Tiers         []int64 `json:"tiers"`
+	ExpireMinutes int     `json:"expire_minutes"`
+	HistoryLimit  int     `json:"history_limit"`
+}
+
+func (c *Client) TTSQueueConfig(ctx context.Context) (*TTSQueueConfig, error) {
+	var out TTSQueueConfig
+	return &out, c.do(ctx, http.MethodGet, "/api/tts/queue/config", nil, &out)
+}
+
+func (c *Client) SetTTSQueueConfig(ctx context.Context, cfg TTSQueueConfig) (*TTSQueueConfig, error) {
+	var out TTSQueueConfig
+	return &out, c.doJSON(ctx, http.MethodPut, "/api/tts/queue/config", nil, cfg, &out)
+}
+
+type Voice struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Engine      string  `json:"engine,omitempty"`
+	EngineVoice string  `json:"engine_voice,omitempty"`
+	Browser     string  `json:"browser,omitempty"`
+	Rate        float64 `json:"rate,omitempty"`
+	Pitch       float64 `json:"pitch,omitempty"`
+	MinTier     int     `json:"min_tier"`
+	Default     bool    `json:"default,omitempty"`
+}
+
+// VoiceOption is a Voice with what it costs; MinAmountCents is -1 when no
+// tier reaches it.
+type VoiceOption struct {
+	Voice
+	MinAmountCents int64 `json:"min_amount_cents"`
+	Unlocked       bool  `json:"unlocked"`
+}
+
+type VoiceList struct {
+	Voices  []VoiceOption `json:"voices"`
+	Default string        `json:"default,omitempty"`
+}
+
+// TTSVoices lists the voice catalog; amountCents marks which voices that
+// donation unlocks and which one it gets by default.
+func (c *Client) TTSVoices(ctx context.Context, amountCents int64) (*VoiceList, error) {
+	var out VoiceList
+	q := url.Values{"amount_cents": {strconv.FormatInt(amountCents, 10)}}
+	return &out, c.do(ctx, http.MethodGet, "/api/tts/voices", q, &out)
+}
+
+func (c *Client) SetTTSVoices(ctx context.Context, voices []Voice) ([]Voice, error) {
+	var out struct {
+		Voices []Voice `json:"voices"`
+	}
+	err := c.doJSON(ctx, http.MethodPut, "/api/tts/voices", nil, map[string]any{"voices": voices}, &out)
+	return out.Voices, err
+}
+
+type Pronunciation struct {
+	Match string `json:"match"`
+	Say   string `json:"say"`
+	Regex bool   `json:"regex,omitempty"`
+}
+
+type NormalizeConfig struct {
+	Pronunciations []Pronunciation   `json:"pronunciations"`
+	Emotes         map[string]string `json:"emotes"`
+	StripMarkdown  bool              `json:"strip_markdown"`
+	StripURLs      bool              `json:"strip_urls"`
+	ExpandCurrency bool              `json:"expand_currency"`
+	ExpandNumbers  bool              `json:"expand_numbers"`
+}
+
+func (c *Client) TTSNormalize(ctx context.Context) (*NormalizeConfig, error) {
+	var out NormalizeConfig
+	return &out, c.do(ctx, http.MethodGet, "/api/tts/normalize", nil, &out)
+}
+
+func (c *Client) SetTTSNormalize(ctx context.Context, cfg NormalizeConfig) (*NormalizeConfig, error) {
+	var out NormalizeConfig
+	return &out, c.doJSON(ctx, http.MethodPut, "/api/tts/normalize", nil, cfg, &out)
+}
+
+// PreviewTTS returns text as the engine would be given it.
+func (c *Client) PreviewTTS(ctx context.Context, text string) (string, error) {
+	var out struct {
+		Spoken string `json:"spoken"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/tts/normalize/preview", url.Values{"text": {text}}, &out)
+	return out.Spoken, err
+}
+
+type CharBudget struct {
+	MinAmountCents int64 `json:"min_amount_cents"`
+	MaxChars       int   `json:"max_chars"`
+}
+
+type TTSLimits struct {
+	Budgets         []CharBudget `json:"budgets"`
+	CooldownSeconds int          `json:"cooldown_seconds"`
+}
+
+func (c *Client) TTSLimits(ctx context.Context) (*TTSLimits, error) {
+	var out TTSLimits
+	return &out, c.do(ctx, http.MethodGet, "/api/tts/limits", nil, &out)
+}
+
+func (c *Client) SetTTSLimits(ctx context.Context, l TTSLimits) (*TTSLimits, error) {
+	var out TTSLimits
+	return &out, c.doJSON(ctx, http.MethodPut, "/api/tts/limits", nil, l, &out)
+}
+
+func (c *Client) TTSModeration(ctx context.Context) (*ModerationConfig, error) {
+	var out ModerationConfig
+	return &out, c.do(ctx, http.MethodGet, "/api/tts/moderation", nil, &out)
+}
+
+func (c *Client) SetTTSModeration(ctx context.Context, cfg ModerationConfig) (*ModerationConfig, error) {
+	var out ModerationConfig
+	return &out, c.doJSON(ctx, http.MethodPut, "/api/tts/moderation", nil, cfg, &out)
+}
+
+// TTSRule auto-approves submissions meeting every condition it sets.
+type TTSRule struct {
+	ID             string   `json:"id"`
+	Name           string   `json:"name,omitempty"`
+	Enabled        bool     `json:"enabled"`
+	MinAmountCents int64    `json:"min_amount_cents,omitempty"`
+	RequireClean   bool     `json:"require_clean,omitempty"`
+	MaxScore       int      `json:"max_score,omitempty"`
+	Donors         []string `json:"donors,omitempty"`
+}
+
+type AutoApproveConfig struct {
+	ManualOnly bool      `json:"manual_only"`
+	Rules      []TTSRule `json:"rules"`
+}
+
+func (c *Client) TTSRules(ctx context.Context) (*AutoApproveConfig, error) {
+	var out AutoApproveConfig
+	return &out, c.do(ctx, http.MethodGet, "/api/tts/rules", nil, &out)
+}
+
+// SetTTSRules replaces the rule list; the manual-only switch is untouched.
+func (c *Client) SetTTSRules(ctx context.Context, rules []TTSRule) (*AutoApproveConfig, error) {
+	var out AutoApproveConfig
+	return &out, c.doJSON(ctx, http.MethodPut, "/api/tts/rules", nil, map[string]any{"rules": rules}, &out)
+}
+
+func (c *Client) SetTTSManualOnly(ctx context.Context, enabled bool) (*AutoApproveConfig, error) {
+	var out AutoApproveConfig
+	q := url.Values{"enabled": {strconv.FormatBool(enabled)}}
+	return &out, c.do(ctx, http.MethodPost, "/api/tts/manual-only", q, &out)
+}
+
+func (c *Client) ApproveTTS(ctx context.Context, id int) (*TTSItem, error) {
+	var out TTSItem
+	return &out, c.do(ctx, http.MethodPost, "/api/tts/approve", idQuery(id), &out)
+}
+
+func (c *Client) RejectTTS(ctx context.Context, id int) (*TTSItem, error) {
+	var out TTSItem
+	return &out, c.do(ctx, http.MethodPost, "/api/tts/reject", idQuery(id), &out)
+}
+
+// SkipTTS stops the given item, or whatever has played longest when id is 0.
+func (c *Client) SkipTTS(ctx context.Context, id int) (*TTSItem, error) {
+	var out TTSItem
+	var q url.Values
+	if id != 0 {
+		q = idQuery(id)
+	}
+	return &out, c.do(ctx, http.MethodPost, "/api/tts/skip", q, &out)
+}
+
+func (c *Client) ReplayTTS(ctx context.Context, id int) (*TTSItem, error) {
+	var out TTSItem
+	return &out, c.do(ctx, http.MethodPost, "/api/tts/replay", idQuery(id), &out)
+}
+
+type TTSHistoryPage struct {
+	Items  []TTSItem `json:"items"`
+	Total  int       `json:"total"`
+	Limit  int       `json:"limit"`
+	Offset int       `json:"offset"`
+}
+
+// TTSHistory pages through finished items, newest first. statuses filters
+// by spoken, skipped, rejected or expired; none means all.
+func (c *Client) TTSHistory(ctx context.Context, limit, offset int, statuses ...string) (*TTSHistoryPage, error) {
+	var out TTSHistoryPage
+	q := url.Values{"limit": {strconv.Itoa(limit)}, "offset": {strconv.Itoa(offset)}}
+	if len(statuses) > 0 {
+		q.Set("status", strings.Join(statuses, ","))
+	}
+	return &out, c.do(ctx, http.MethodGet, "/api/tts/history", q, &out)
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Requests
+// ─────────────────────────────────────────────────────────────────────────────
+
+// SubmitRequest files a request and returns its tracking receipt rather
+// than the stored item.
+func (c *Client) SubmitRequest(ctx context.Context, s RequestSubmission) (*SubmitReceipt, error) {
+	q := url.Values{}
+	setIf(q, "board", s.Board)
+	setIf(q, "phone", s.Phone)
+	setIf(q, "region", s.Region)
+	setIf(q, "note", s.Note)
+	setIf(q, "donor", s.Donor)
+	var out SubmitReceipt
+	return &out, c.do(ctx, http.MethodGet, "/api/request/submit", q, &out)
+}
+
+// RequestStatus looks a request up by the token from its SubmitReceipt. It
+// needs no credentials.
+func (c *Client) RequestStatus(ctx context.Context, token string) (*PublicStatus, error) {
+	var out PublicStatus
+	return &out, c.do(ctx, http.MethodGet, "/api/request/status/"+url.PathEscape(token), nil, &out)
+}
+
+// RequestBoards lists boards with their pending and active counts.
+func (c *Client) RequestBoards(ctx context.Context) ([]BoardSummary, error) {
+	var out []BoardSummary
+	return out, c.do(ctx, http.MethodGet, "/api/request/boards", nil, &out)
+}
+
+// RequestQueue lists pending requests on board, or on all boards if "".
+func (c *Client) RequestQueue(ctx context.Context, board string) ([]RequestItem, error) {
+	q := url.Values{}
+	setIf(q, "board", board)
+	var out []RequestItem
+	return out, c.do(ctx, http.MethodGet, "/api/request/queue", q, &out)
+}
+
+// ActiveRequests lists approved and in-call requests on board, or on all
+// boards if "".
+func (c *Client) ActiveRequests(ctx context.Context, board string) ([]RequestItem, error) {
+	q := url.Values{}
+	setIf(q, "board", board)
+	var out []RequestItem
+	return out, c.do(ctx, http.MethodGet, "/api/request/active", q, &out)
+}
+
+// RequestEdit changes a pending request; nil fields are left alone.
+type RequestEdit struct {
+	Note    *string `json:"note,omitempty"`
+	Board   *string `json:"board,omitempty"`
+	Flagged *bool   `json:"flagged,omitempty"`
+}
+
+// BulkReject selects pending requests to reject. All set filters must
+// match, and at least one must be set.
+type BulkReject struct {
+	Board            string `json:"board,omitempty"`
+	OlderThanMinutes int    `json:"older_than_minutes,omitempty"`
+	Flagged          bool   `json:"flagged,omitempty"`
+	Note             string `json:"note,omitempty"`
+}
+
+// EditRequest changes a pending request's note, board or flag.
+func (c *Client) EditRequest(ctx context.Context, id int, e RequestEdit) (*RequestItem, error) {
+	var out RequestItem
+	return &out, c.doJSON(ctx, http.MethodPost, "/api/request/edit", idQuery(id), e, &out)
+}
+
+// MoveRequest moves a pending request one place up (up=true) or down its
+// board's queue.
+func (c *Client) MoveRequest(ctx context.Context, id int, up bool) (*RequestItem, error) {
+	q := idQuery(id)
+	q.Set("dir", "down")
+	if up {
+		q.Set("dir", "up")
+	}
+	var out RequestItem
+	return &out, c.do(ctx, http.MethodPost, "/api/request/move", q, &out)
+}
+
+// PinRequest pins a pending request to the top of the queue, or unpins it.
+func (c *Client) PinRequest(ctx context.Context, id int, pinned bool) (*RequestItem, error) {
+	q := idQuery(id)
+	q.Set("pinned", strconv.FormatBool(pinned))
+	var out RequestItem
+	return &out, c.do(ctx, http.MethodPost, "/api/request/pin", q, &out)
+}
+
+// BulkRejectRequests rejects the pending requests matching f and returns
+// their IDs.
+func (c *Client) BulkRejectRequests(ctx context.Context, f BulkReject) ([]int, error) {
+	var out struct {
+		Rejected []int `json:"rejected"`
+	}
+	err := c.doJSON(ctx, http.MethodPost, "/api/request/bulk-reject", nil, f, &out)
+	return out.Rejected, err
+}
+
+// HeldRequests lists requests awaiting payment on board, or on all boards
+// if "".
+func (c *Client) HeldRequests(ctx context.Context, board string) ([]RequestItem, error) {
+	q := url.Values{}
+	setIf(q, "board", board)
+	var out []RequestItem
+	return out, c.do(ctx, http.MethodGet, "/api/request/held", q, &out)
+}
+
+// MarkRequestPaid releases a request awaiting payment to the pending queue,
+// for payments taken outside the donation intake.
+func (c *Client) MarkRequestPaid(ctx context.Context, id int) (*RequestItem, error) {
+	var out RequestItem
+	return &out, c.do(ctx, http.MethodPost, "/api/request/mark-paid", idQuery(id), &out)
+}
+
+func (c *Client) ApproveRequest(ctx context.Context, id int) (*RequestItem, error) {
+	var out RequestItem
+	return &out, c.do(ctx, http.MethodPost, "/api/request/approve", idQuery(id), &out)
+}
+
+func (c *Client) RejectRequest(ctx context.Context, id int) (*RequestItem, error) {
+	var out RequestItem
+	return &out, c.do(ctx, http.MethodPost, "/api/request/reject", idQuery(id), &out)
+}
+
+// StartRequest marks an approved request as on the call.
+func (c *Client) StartRequest(ctx context.Context, id int) (*RequestItem, error) {
+	var out RequestItem
+	return &out, c.do(ctx, http.MethodPost, "/api/request/start", idQuery(id), &out)
+}
+
+// CompleteRequest ends a call with outcome "completed", "failed" or
+// "no_answer" ("" means completed) and an optional note.
+func (c *Client) CompleteRequest(ctx context.Context, id int, outcome, note string) (*RequestItem, error) {
+	q := idQuery(id)
+	setIf(q, "outcome", outcome)
+	setIf(q, "note", note)
+	var out RequestItem
+	return &out, c.do(ctx, http.MethodPost, "/api/request/complete", q, &out)
+}
+
+// PhoneRegions lists supported countries and the server's default.
+func (c *Client) PhoneRegions(ctx context.Context) (*PhoneRegionList, error) {
+	var out PhoneRegionList
+	return &out, c.do(ctx, http.MethodGet, "/api/request/regions", nil, &out)
+}
+
+// RevealPhone returns a request's full phone number in E.164 form. The client's Token
+// must belong to an operator with the reveal_phone role; the server audits
+// every call.
+func (c *Client) RevealPhone(ctx context.Context, id int) (string, error) {
+	var out struct {
+		Phone string `json:"phone"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/request/reveal", idQuery(id), &out); err != nil {
+		return "", err
+	}
+	return out.Phone, nil
+}
+
+// BlockEntry is a number or board whose submissions are rejected. Value is
+// a keyed hash for numbers; Label is what to show people.
+type BlockEntry struct {
+	ID        int    `json:"id"`
+	Kind      string `json:"kind"`
+	Value     string `json:"value"`
+	Label     string `json:"label"`
+	Reason    string `json:"reason,omitempty"`
+	AddedBy   string `json:"added_by"`
+	AddedUnix int64  `json:"added_unix"`
+}
+
+// BlockInput names what to block: exactly one of Phone (with optional
+// Region), Board or RequestID.
+type BlockInput struct {
+	Phone     string `json:"phone,omitempty"`
+	Region    string `json:"region,omitempty"`
+	Board     string `json:"board,omitempty"`
+	RequestID int    `json:"request_id,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+func (c *Client) Blocklist(ctx context.Context) ([]BlockEntry, error) {
+	var out []BlockEntry
+	return out, c.do(ctx, http.MethodGet, "/api/request/blocklist", nil, &out)
+}
+
+func (c *Client) Block(ctx context.Context, in BlockInput) (*BlockEntry, error) {
+	var out BlockEntry
+	return &out, c.doJSON(ctx, http.MethodPost, "/api/request/blocklist", nil, in, &out)
+}
+
+func (c *Client) Unblock(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodPost, "/api/request/blocklist/remove", idQuery(id), nil)
+}
+
+// DuplicateConfig controls repeat-submission detection; Action is "merge"
+// or "reject" and WindowMinutes 0 disables it.
+type DuplicateConfig struct {
+	WindowMinutes int    `json:"window_minutes"`
+	Action        string `json:"action"`
+	MatchPhone    bool   `json:"match_phone"`
+	MatchBoard    bool   `json:"match_board"`
+}
+
+func (c *Client) RequestDuplicates(ctx context.Context) (*DuplicateConfig, error) {
+	var out DuplicateConfig
+	return &out, c.do(ctx, http.MethodGet, "/api/request/duplicates", nil, &out)
+}
+
+func (c *Client) SetRequestDuplicates(ctx context.Context, cfg DuplicateConfig) (*DuplicateConfig, error) {
+	var out DuplicateConfig
+	return &out, c.doJSON(ctx, http.MethodPut, "/api/request/duplicates", nil, cfg, &out)
+}
+
+type RequestLogPage struct {
+	Items  []RequestItem `json:"items"`
+	Total  int           `json:"total"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
+
+// RequestLog searches finished requests, newest first, on board ("" for
+// all). search matches
+// board, notes, masked phone, screening reason and operator; statuses
+// filters by final state.
+func (c *Client) RequestLog(ctx context.Context, board, search string, limit, offset int, statuses ...string) (*RequestLogPage, error) {
+	q := url.Values{"limit": {strconv.Itoa(limit)}, "offset": {strconv.Itoa(offset)}}
+	setIf(q, "board", board)
+	setIf(q, "q", search)
+	if len(statuses) > 0 {
+		q.Set("status", strings.Join(statuses, ","))
+	}
+	var out RequestLogPage
+	return &out, c.do(ctx, http.MethodGet, "/api/request/log", q, &out)
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// State
+// ─────────────────────────────────────────────────────────────────────────────
+
+func (c *Client) SaveState(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/api/state/save", nil, nil)
+}
+
+func (c *Client) Rehydrate(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/api/state/rehydrate", nil, nil)
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Transport
+// ─────────────────────────────────────────────────────────────────────────────
+
+func idQuery(id int) url.Values { return url.Values{"id": {strconv.Itoa(id)}} }
+
+func setIf(q url.Values, k, v string) {
+	if v != "" {
+		q.Set(k, v)
+	}
+}
+
+// do sends a request and decodes the response's "data" member into out
+// (if non-nil).
+func (c *Client) do(ctx context.Context, method, path string, q url.Values, out any) error {
+	return c.doJSON(ctx, method, path, q, nil, out)
+}
+
+// doJSON is do with an optional JSON request body.
+func (c *Client) doJSON(ctx context.Context, method, path string, q url.Values, in, out any) error {
+	u := c.BaseURL + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{Status: resp.StatusCode}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			apiErr.RetryAfter = time.Duration(secs) * time.Second
+		}
+		var body struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+				Field   string `json:"field"`
+			} `json:"error"`
+		}
+		raw, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(raw, &body) == nil && body.Error.Code != "" {
+			apiErr.Code, apiErr.Message, apiErr.Field = body.Error.Code, body.Error.Message, body.Error.Field
+		} else {
+			apiErr.Message = strings.TrimSpace(string(raw))
+		}
+		return apiErr
+	}
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(&struct {
+		Data any `json:"data"`
+	}{Data: out})
+}
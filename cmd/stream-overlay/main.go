@@ -3,12 +3,19 @@ package main
 import (
 	_ "embed"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/dtorres47/stream-overlay/internal/abilities"
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/auth"
 	"github.com/dtorres47/stream-overlay/internal/catalog"
+	"github.com/dtorres47/stream-overlay/internal/health"
 	"github.com/dtorres47/stream-overlay/internal/history"
+	"github.com/dtorres47/stream-overlay/internal/logging"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
 	"github.com/dtorres47/stream-overlay/internal/quests"
 	"github.com/dtorres47/stream-overlay/internal/requests"
 	"github.com/dtorres47/stream-overlay/internal/state"
@@ -25,14 +32,34 @@ var overlayHTML []byte
 //go:embed web/panel.html
 var panelHTML []byte
 
+//go:embed web/status.html
+var statusHTML []byte
+
 func main() {
+	logging.Setup()
+	auth.Setup()
+	if err := requests.SetupPhoneKey(); err != nil {
+		slog.Error("phone encryption unavailable; requests with a phone number will be refused", "err", err)
+	}
+
 	// Load catalog & restore saved state
 	//catalog.LoadCatalogFromDisk()
-	catalog.LoadCatalog()
+	if err := catalog.LoadCatalog(); err != nil {
+		slog.Error("catalog unavailable; readiness will fail", "err", err)
+	}
 	state.LoadState()
+	tts.SetupSynthesizer()
+	tts.ResumeApproved()
+	tts.StartExpiry(30 * time.Second)
+	requests.StartExpiry(time.Minute)
+	requests.StartPhonePurge(time.Hour, state.SaveState)
+	quests.StartArchiver(5 * time.Second)
+	quests.StartTimers(time.Second)
 
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
+	r.Use(middleware.RequestID)
+	r.Use(logging.RequestLogger)
+	r.Use(metrics.Middleware)
 	r.Use(middleware.RedirectSlashes)
 
 	// Serve static assets (css/js)
@@ -59,6 +86,8 @@ func main() {
   <li><a href="/overlay" target="_blank">Overlay</a></li>
   <li><a href="/panel" target="_blank">Panel</a></li>
   <li><a href="/api/debug/clients" target="_blank">Client Count</a></li>
+  <li><a href="/metrics" target="_blank">Metrics</a></li>
+  <li><a href="/api/openapi.json" target="_blank">OpenAPI</a></li>
 </ul>`, ws.ClientsCount())
 	})
 
@@ -75,30 +104,44 @@ func main() {
 		w.Write(panelHTML)
 	})
 
+	// Public request status page (?token=)
+	r.Get("/request-status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(statusHTML)
+	})
+
 	// API routes
+	api.RegisterRoutes(r)
 	catalog.RegisterRoutes(r)
+	abilities.RegisterRoutes(r)
 	quests.RegisterRoutes(r)
 	tts.RegisterRoutes(r)
 	requests.RegisterRoutes(r)
 	state.RegisterRoutes(r)
 
 	// Donation-history endpoint
-	r.Post("/api/donations", history.RecordDonation)
+	r.With(auth.Require(history.RoleRecordDonations)).Post("/api/donations", history.RecordDonation)
+
+	// Runtime log-level tuning
+	logging.RegisterRoutes(r)
+
+	// Prometheus scrape endpoint
+	r.Get("/metrics", metrics.Handler)
 
 	// Debug & health
 	r.Get("/api/debug/clients", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "%d\n", ws.ClientsCount())
 	})
-	r.Get("/api/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		fmt.Fprint(w, `{"status":"ok","service":"stream-overlay"}`)
-	})
+	health.RegisterRoutes(r)
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "3000"
 	}
 	addr := ":" + port
-	log.Printf("Server listening on http://localhost%v", addr)
-	log.Fatal(http.ListenAndServe(addr, r))
+	slog.Info("server listening", "addr", "http://localhost"+addr)
+	if err := http.ListenAndServe(addr, r); err != nil {
+		slog.Error("server stopped", "err", err)
+		os.Exit(1)
+	}
 }
@@ -8,6 +8,8 @@ const wsStatus       = document.getElementById("wsStatus");
 // state
 const cooldown    = new Map();
 const questElems  = new Map();
+const fundingElems= new Map();
+const questTimers = new Map(); // id → { endAt (local ms) or null while paused, remaining (s) }
 const requestElems= new Map();
 const audCache    = new Map();
 let audioEnabled  = false;
@@ -64,20 +66,6 @@ bc.onmessage = ev => {
 };
 
 
-// DATA: record donation history
-function recordDonation(d) {
-    fetch('/api/donations', {
-        method: 'POST',
-        headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({
-            time:    new Date().toISOString(),
-            donor:   d.donor || "Anonymous",
-            amount:  d.amount || 0,
-            message: d.msg || ""
-        })
-    }).catch(console.warn);
-}
-
 // audio gate
 function enableAudio() {
     const a = new Audio("data:audio/mp3;base64,//uQZ...");
@@ -140,7 +128,37 @@ function playAbility(id, url, volume) {
     } catch { beep(); }
 }
 
-function speak(text, voiceHint) {
+// Server-rendered TTS: every overlay plays the same file. Falls back to the
+// browser's speechSynthesis when the server sent text only.
+// The server keeps the item "playing" until we report TTS_DONE.
+let ttsAudio = null;
+let ttsPlayingId = null;
+function ttsDone(id) {
+    if (ttsPlayingId !== id) return;
+    ttsPlayingId = null;
+    if (ws && ws.readyState === WebSocket.OPEN)
+        ws.send(JSON.stringify({ type: "TTS_DONE", data: { id } }));
+}
+function playTTS(d) {
+    stopTTS();
+    ttsPlayingId = d.id;
+    const fallback = () => speak(d.text, d.voice, d.rate, d.pitch, () => ttsDone(d.id));
+    if (!d.url) { fallback(); return; }
+    try {
+        ttsAudio = new Audio(d.url);
+        ttsAudio.volume = 1;
+        ttsAudio.onended = () => ttsDone(d.id);
+        ttsAudio.play().catch(fallback);
+    } catch { fallback(); }
+}
+function stopTTS(id) {
+    if (id && ttsPlayingId !== id) return;
+    ttsPlayingId = null;
+    if (ttsAudio) { ttsAudio.onended = null; ttsAudio.pause(); ttsAudio = null; }
+    if ("speechSynthesis" in window) speechSynthesis.cancel();
+}
+
+function speak(text, voiceHint, rate, pitch, onEnd) {
     if (!("speechSynthesis" in window)) { toast("TTS not supported"); return; }
     if (!text) return;
     const u = new SpeechSynthesisUtterance(text);
@@ -149,7 +167,8 @@ function speak(text, voiceHint) {
         const match  = voices.find(v => v.name.toLowerCase().includes(voiceHint.toLowerCase()));
         if (match) u.voice = match;
     }
-    u.rate = 1; u.pitch = 1; u.volume = 1;
+    u.rate = rate || 1; u.pitch = pitch || 1; u.volume = 1;
+    if (onEnd) u.onend = onEnd;
     speechSynthesis.speak(u);
 }
 if ('speechSynthesis' in window) {
@@ -163,11 +182,6 @@ function renderQuest(d) {
     const id = d.id; if (!id) return;
     let el = questElems.get(id);
 
-    el.dataset.name     = d.name;
-    el.dataset.icon     = d.icon_url;
-    el.dataset.target   = d.target;
-    el.dataset.progress = d.progress;
-
     const progress = Number(d.progress||0),
         target   = Math.max(1, Number(d.target||1));
     const label = `${d.name||"Quest"} — ${progress}/${target}`;
@@ -183,40 +197,154 @@ function renderQuest(d) {
         questList.appendChild(el);
         questElems.set(id, el);
     }
-    el.innerHTML = html;
-    const done = progress >= target;
-    el.style.opacity = done ? "0.75" : "1";
+    el.dataset.name     = d.name;
+    el.dataset.icon     = d.icon_url;
+    el.dataset.target   = d.target;
+    el.dataset.progress = d.progress;
+    el.innerHTML = html + `<span class="quest-timer"></span>`;
+    const done = progress >= target, failed = !!d.failed_unix;
+    el.classList.toggle("failed", failed);
+    el.style.opacity = done || failed ? "0.75" : "1";
     el.style.textDecoration = done ? "line-through" : "none";
+    if (done || failed) questTimers.delete(id);
+    renderTimer(id);
+}
+
+// Quest countdowns: the server sends the remaining seconds with every
+// QUEST_TIMER, so the end time is worked out against this machine's clock
+function setQuestTimer(d) {
+    if (!d.id) return;
+    const remaining = Math.max(0, Number(d.remaining_seconds || 0));
+    questTimers.set(d.id, { endAt: d.paused ? null : Date.now() + remaining * 1000, remaining });
+    renderTimer(d.id);
+}
+function renderTimer(id) {
+    const el = questElems.get(id);
+    const span = el && el.querySelector(".quest-timer");
+    if (!span) return;
+    const t = questTimers.get(id);
+    if (!t) { span.textContent = ""; return; }
+    const secs = t.endAt === null ? t.remaining : Math.max(0, Math.ceil((t.endAt - Date.now()) / 1000));
+    const mmss = `${Math.floor(secs / 60)}:${String(secs % 60).padStart(2, "0")}`;
+    span.textContent = t.endAt === null ? ` ⏸ ${mmss}` : ` ⏱ ${mmss}`;
+    span.classList.toggle("urgent", t.endAt !== null && secs <= 30);
+}
+setInterval(() => questTimers.forEach((_, id) => renderTimer(id)), 1000);
+function failQuest(d) {
+    const q = d.quest || {};
+    questTimers.delete(q.id);
+    renderQuest(q);
+    beep(400, 220);
+    toast(`⌛ Quest failed: ${q.name || q.id}`);
+}
+// celebrateQuest plays a quest's completion: its catalog sound, animation
+// and spoken line, each falling back to a default
+function celebrateQuest(d) {
+    const q = d.quest || {}, c = d.celebration || {};
+    questTimers.delete(q.id);
+    renderQuest(q);
+    const el = questElems.get(q.id);
+    if (el) {
+        el.style.animation = "none";
+        void el.offsetWidth; // restart the animation if it is already set
+        el.style.animation = `${c.animation || "quest-complete"} 1.2s ease-in-out 3`;
+    }
+    c.sound_url ? playAbility(`quest:${q.id}`, c.sound_url) : beep(300, 660);
+    toast(`🏆 Quest complete: ${q.name || q.id}`);
+    if (c.tts) speak(c.tts);
 }
 function removeQuest(id) {
+    questTimers.delete(id);
     const el = questElems.get(id);
     if (el) { el.remove(); questElems.delete(id); }
 }
+// renderFunding shows a crowdfunded quest's pot as a bar toward its price;
+// an empty pot (just bought, or never started) is removed
+function renderFunding(d) {
+    const id = d.id; if (!id) return;
+    let el = fundingElems.get(id);
+    const raised = Number(d.raised_cents || 0), goal = Math.max(1, Number(d.goal_cents || 1));
+    if (raised <= 0) {
+        if (el) { el.remove(); fundingElems.delete(id); }
+        return;
+    }
+    if (!el) {
+        el = document.createElement("div");
+        el.className = "quest-funding"; el.dataset.id = id;
+        el.innerHTML = `<div class="funding-label"></div><div class="funding-bar"><div class="funding-fill"></div></div>`;
+        questList.appendChild(el);
+        fundingElems.set(id, el);
+    }
+    const backers = new Set((d.contributors || []).map(c => c.donor)).size;
+    el.querySelector(".funding-label").textContent =
+        `💰 ${d.name || id} — $${(raised/100).toFixed(2)} / $${(goal/100).toFixed(2)} (${backers} backer${backers === 1 ? "" : "s"})`;
+    el.querySelector(".funding-fill").style.width = `${Math.min(100, raised / goal * 100)}%`;
+}
 
 // requests rendering
+
+// regionFlag turns an ISO country code into its flag emoji ("GB" → 🇬🇧).
+function regionFlag(code) {
+    if (!/^[A-Z]{2}$/.test(code || "")) return "";
+    return String.fromCodePoint(...[...code].map(c => 0x1F1E6 + c.charCodeAt(0) - 65)) + " ";
+}
+// boardSection returns the overlay section for a board, creating it with
+// the board's catalog styling the first time one of its requests shows up.
+function boardSection(d) {
+    const key = d.board || "_";
+    let sec = requestsList.querySelector(`.board-section[data-board="${CSS.escape(key)}"]`);
+    if (!sec) {
+        const style = d.board_style || {};
+        sec = document.createElement("div");
+        sec.className = "board-section"; sec.dataset.board = key;
+        if (style.color) sec.style.setProperty("--board-color", style.color);
+        sec.innerHTML = `<h4 class="board-title"></h4><div class="board-items"></div>`;
+        sec.querySelector(".board-title").textContent = `${style.icon || "📞"} ${d.board_name || d.board || "Requests"}`;
+        requestsList.appendChild(sec);
+    }
+    return sec.querySelector(".board-items");
+}
 function renderRequest(d) {
     const id = d.id; if (!id) return;
     let el = requestElems.get(id);
-    const label = `${d.board||"(request)"}${d.note?" — "+d.note:""}`;
-    const phone = d.masked_phone ? ` <small style="color:#9f9">(${d.masked_phone})</small>` : "";
-    const html  = `📞 ${label}${phone}`;
+    const label = d.note || "(request)";
+    const phone = d.masked_phone ? ` <small>(${regionFlag(d.phone_region)}${d.masked_phone})</small>` : "";
+    const repeats = d.duplicates ? ` <small>×${d.duplicates + 1}</small>` : "";
+    const html  = `${label}${phone}${repeats}`;
     if (!el) {
         el = document.createElement("div");
         el.className = "request"; el.dataset.id = id;
-        requestsList.appendChild(el);
+        boardSection(d).appendChild(el);
         requestElems.set(id, el);
     }
     el.innerHTML = html;
+    el.classList.toggle("in-call", d.status === "in_call");
+}
+// updateRequest applies a status change, or an edit carrying the whole
+// request (which may have moved it to another board's section)
+function updateRequest(d) {
+    const el = requestElems.get(d.id);
+    if (!el) return;
+    if (d.board_name === undefined) {
+        el.classList.toggle("in-call", d.status === "in_call");
+        return;
+    }
+    const sec = el.closest(".board-section");
+    if (sec && sec.dataset.board !== (d.board || "_")) removeRequest(d.id);
+    renderRequest(d);
 }
 function removeRequest(id) {
     const el = requestElems.get(id);
-    if (el) { el.remove(); requestElems.delete(id); }
+    if (!el) return;
+    const sec = el.closest(".board-section");
+    el.remove(); requestElems.delete(id);
+    if (sec && !sec.querySelector(".request")) sec.remove();
 }
 
 // preload ability sounds from /api/catalog
 async function preloadSounds() {
     try {
-        const data = await fetch('/api/catalog').then(r=>r.json());
+        const data = await fetch('/api/catalog').then(r=>r.json()).then(b=>b.data||{});
         const list = data.abilities||[];
         let count = 0;
         list.forEach(a=>{
@@ -245,7 +373,7 @@ let ws, retry = 0;
 
 function connectWS() {
     wsStatus.textContent = "WS: connecting";
-    ws = new WebSocket(wsUrl);
+    ws = new WebSocket(wsUrl + "?role=overlay");
 
     ws.onopen = () => {
         retry = 0;
@@ -261,10 +389,8 @@ function connectWS() {
 
         switch (msg.type) {
             case "DONATION":
-                // record history
-                recordDonation(d);
-
-                // display toast
+                // display only: donations reach the ledger from an operator
+                // or integration with a token, never from an overlay
                 const cents  = Number(d.amount || 0);
                 const dollars= isFinite(cents) ? (cents/100).toFixed(2) : "0.00";
                 toast(`💸 ${d.donor||"Anonymous"} donated $${dollars}${d.msg?" — "+d.msg:""}`);
@@ -284,12 +410,27 @@ function connectWS() {
                 break;
 
             case "TTS_PLAY":
-                speak(d.text, d.voice);
+                playTTS(d);
+                break;
+            case "TTS_STOP":
+                stopTTS(d.id);
                 break;
 
             case "QUEST_UPSERT":
                 renderQuest(d);
                 break;
+            case "QUEST_COMPLETE":
+                celebrateQuest(d);
+                break;
+            case "QUEST_TIMER":
+                setQuestTimer(d);
+                break;
+            case "QUEST_FAILED":
+                failQuest(d);
+                break;
+            case "QUEST_FUNDING":
+                renderFunding(d);
+                break;
             case "QUEST_ADD":
                 d.progress = d.progress || 0;
                 d.target   = d.target   || 1;
@@ -302,6 +443,9 @@ function connectWS() {
             case "REQUEST_ADD":
                 renderRequest(d);
                 break;
+            case "REQUEST_UPDATE":
+                updateRequest(d);
+                break;
             case "REQUEST_REMOVE":
                 if (d.id) removeRequest(d.id);
                 break;
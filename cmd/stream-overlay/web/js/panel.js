@@ -1,3 +1,10 @@
+// API responses are wrapped as {data: …} or {error: {code, message, field}}
+async function apiGet(url) {
+    const body = await fetch(url).then(r => r.json());
+    if (body.error) throw new Error(body.error.message);
+    return body.data;
+}
+
 // Client count
 const elClients = document.getElementById('clients');
 async function refreshClients() {
@@ -18,10 +25,19 @@ document.getElementById('syncOverlay').onclick = () => fetch('/api/state/rehydra
 document.getElementById('sendDonation').onclick = async () => {
     const donor = document.getElementById('donor').value || 'Viewer';
     const amountD = parseFloat(document.getElementById('amount').value || '0');
-    const cents = Math.max(0, Math.round(amountD * 100));
-    const msg = encodeURIComponent(document.getElementById('msg').value || '');
-    await fetch(`/api/donation?donor=${encodeURIComponent(donor)}&amount_cents=${cents}&msg=${msg}`);
+    const amount = Math.max(0, Math.round(amountD * 100)) / 100;
+    const message = document.getElementById('msg').value || '';
+    const quest = document.getElementById('donQuest').value || '';
+    const res = await operatorFetch('/api/donations', { method:'POST', body: JSON.stringify({ donor, amount, message, quest }) });
+    if (!res) return;
+    if (!res.ok) {
+        const body = await res.json();
+        alert(body.error ? body.error.message : 'Donation failed');
+        return;
+    }
     refreshClients();
+    loadActiveQuests(); // donations can fund quests or advance their triggers
+    loadHeldRequests(); loadRequestQueue(); // a donation may pay for a request
 };
 
 // Direct TTS
@@ -35,10 +51,13 @@ document.getElementById('sendTTS').onclick = async () => {
 
 // Catalog loading
 async function loadCatalog() {
-    const data = await fetch('/api/catalog').then(r => r.json());
+    const data = await apiGet('/api/catalog');
     const abil = document.getElementById('abilities');
     const ques = document.getElementById('quests');
+    const fund = document.getElementById('donQuest');
     abil.innerHTML = ''; ques.innerHTML = '';
+    fund.innerHTML = '<option value="">—</option>';
+    data.quests.filter(q => q.price_cents > 0).forEach(q => fund.add(new Option(`${q.name} ($${(q.price_cents/100).toFixed(2)})`, q.id)));
 
     data.abilities.forEach(a => {
         const d = document.createElement('div');
@@ -48,7 +67,7 @@ async function loadCatalog() {
       <br/><small class="mono">${a.id}</small></div>`;
         const b = document.createElement('button');
         b.textContent = 'Fire';
-        b.onclick = () => fetch(`/api/ability/fire?id=${encodeURIComponent(a.id)}`);
+        b.onclick = () => fetch(`/api/ability/fire?id=${encodeURIComponent(a.id)}`, { method:'POST' }).then(loadActiveQuests);
         d.appendChild(b);
         abil.appendChild(d);
     });
@@ -57,9 +76,10 @@ async function loadCatalog() {
         const d = document.createElement('div');
         d.className = 'item';
         const tgt = q.target || 1;
+        const auto = (q.triggers || []).map(t => t.per_dollar ? `$1 donated` : `${t.on}${t.id ? ' '+t.id : ''}${t.step > 1 ? ' ×'+t.step : ''}`);
         d.innerHTML = `<div><strong>${q.name}</strong>
       <small class="mono">($${((q.price_cents||0)/100).toFixed(2)}, target ${tgt})</small>
-      <br/><small class="mono">${q.id}</small></div>`;
+      <br/><small class="mono">${q.id}${auto.length ? ' — +1 per '+auto.join(', ') : ''}</small></div>`;
         const b = document.createElement('button');
         b.textContent = 'Start';
         b.onclick = async () => { await fetch(`/api/quest/add?id=${encodeURIComponent(q.id)}`); loadActiveQuests(); };
@@ -70,14 +90,23 @@ async function loadCatalog() {
     loadActiveQuests();
 }
 
+// questClock is a timed quest's remaining time, or '' if it has none
+function questClock(qs) {
+    if (qs.failed_unix) return ' ✗ failed';
+    if (qs.completed_unix) return '';
+    const secs = qs.deadline_unix ? Math.max(0, qs.deadline_unix - Math.floor(Date.now() / 1000)) : qs.paused_remaining_seconds;
+    if (!secs) return '';
+    return ` ${qs.deadline_unix ? '⏱' : '⏸'} ${Math.floor(secs / 60)}:${String(secs % 60).padStart(2, '0')}`;
+}
+
 // Active quests
 async function loadActiveQuests() {
-    const data = await fetch('/api/quest/active').then(r => r.json());
+    const data = await apiGet('/api/quest/active');
     const list = document.getElementById('active');
     list.innerHTML = data.length ? '' : '<div class="item"><em>None yet</em></div>';
     data.forEach(qs => {
         const d = document.createElement('div'); d.className = 'item';
-        d.innerHTML = `<div><strong>${qs.name}</strong> <small class="mono">${qs.progress}/${qs.target}</small>
+        d.innerHTML = `<div><strong>${qs.name}</strong> <small class="mono">${qs.progress}/${qs.target}${qs.completed_unix ? ' ✓ complete' : ''}${questClock(qs)}${qs.repeats ? ` (+${qs.repeats} run${qs.repeats > 1 ? 's' : ''} paid)` : ''}</small>
       <br/><small class="mono">${qs.id}</small></div>`;
         const btns = document.createElement('div'); btns.className='btns';
         ['+1','Reset','Remove'].forEach((txt,i) => {
@@ -94,21 +123,84 @@ async function loadActiveQuests() {
         d.appendChild(btns);
         list.appendChild(d);
     });
+    loadFunding();
+    loadCompleted();
+    loadTimers();
     refreshClients();
 }
 
+// Quest timers are paused while the stream is on break
+async function loadTimers() {
+    const t = await apiGet('/api/quest/timers');
+    const btn = document.getElementById('pauseTimers');
+    btn.textContent = t.paused ? 'Resume timers' : 'Pause timers (break)';
+    btn.onclick = async () => {
+        await fetch(`/api/quest/timers?paused=${!t.paused}`, { method:'POST' });
+        loadActiveQuests();
+    };
+}
+
+// Pledges toward crowdfunded quests not yet bought
+async function loadFunding() {
+    const pots = await apiGet('/api/quest/funding');
+    const list = document.getElementById('funding');
+    list.innerHTML = pots.length ? '' : '<div class="item"><em>No pledges</em></div>';
+    pots.forEach(f => {
+        const d = document.createElement('div'); d.className = 'item';
+        const backers = [...new Set(f.contributors.map(c => c.donor))].join(', ');
+        d.innerHTML = `<div><strong>${f.name}</strong> <small class="mono">$${(f.raised_cents/100).toFixed(2)} / $${(f.goal_cents/100).toFixed(2)}</small>
+      <br/><small class="mono">${backers}</small></div>`;
+        list.appendChild(d);
+    });
+}
+
+// Quests finished in the current stream session, failed runs included;
+// finished quests leave the active list once their grace period is over
+async function loadCompleted() {
+    const [cur] = await apiGet('/api/quest/completed');
+    const list = document.getElementById('completed');
+    document.getElementById('doneTitle').textContent = `Completed this stream (session ${cur.id})`;
+    list.innerHTML = cur.quests.length ? '' : '<div class="item"><em>None yet</em></div>';
+    cur.quests.slice().reverse().forEach(q => {
+        const d = document.createElement('div'); d.className = 'item';
+        d.innerHTML = `<div><strong>${q.name}</strong> <small class="mono">${q.progress}/${q.target}${q.failed_unix ? ' ✗ failed' : ''}</small>
+      <br/><small class="mono">${new Date((q.completed_unix || q.failed_unix) * 1000).toLocaleTimeString()}</small></div>`;
+        list.appendChild(d);
+    });
+}
+document.getElementById('newSession').onclick = async () => {
+    if (!confirm('Start a new stream session? Completed quests will be listed under it from now on.')) return;
+    await fetch('/api/quest/session', { method:'POST' });
+    loadCompleted();
+};
+
 // TTS Queue
 async function loadQueue() {
     const qList = document.getElementById('qList');
-    const items = await fetch('/api/tts/queue').then(r => r.json());
+    const items = await apiGet('/api/tts/queue');
     qList.innerHTML = items.length ? '' : '<div class="item"><em>None pending</em></div>';
-    items.forEach(it => {
+    items.forEach((it, pos) => {
         const d = document.createElement('div'); d.className='item';
+        // drag a row onto another to take its place in the play order
+        d.draggable = true;
+        d.ondragstart = e => e.dataTransfer.setData('text/plain', String(it.id));
+        d.ondragover = e => { e.preventDefault(); d.classList.add('dragover'); };
+        d.ondragleave = () => d.classList.remove('dragover');
+        d.ondrop = async e => {
+            e.preventDefault();
+            const id = e.dataTransfer.getData('text/plain');
+            if (id && id !== String(it.id)) await fetch(`/api/tts/move?id=${id}&to=${pos}`, { method:'POST' });
+            loadQueue();
+        };
         const dollars = (Number(it.amount_cents||0)/100).toFixed(2);
+        const flags = (it.flags||[]).map(f => `<span class="flag" title="${f.detail||''}">${f.code}</span>`).join(' ');
+        if (flags) d.classList.add('flagged');
         d.innerHTML = `<div><strong>${it.text}</strong><br/>
-      <small class="mono">${it.voice||'default'}</small> |
+      <small class="mono">${it.voice||'default'}${it.voice_wanted ? ` (wanted ${it.voice_wanted})` : ''}</small> |
       <small class="mono">${it.donor||'Anonymous'}</small> |
-      <small class="mono">$${dollars}</small></div>`;
+      <small class="mono">$${dollars}</small>
+      ${it.tier ? `<span class="tier">T${it.tier}</span>` : ''}
+      ${flags ? `<br/>${flags} <small class="mono">score ${it.score||0}</small>` : ''}</div>`;
         const btns = document.createElement('div'); btns.className='btns';
         ['Approve','Reject'].forEach((txt,i) => {
             const btn = document.createElement('button');
@@ -125,7 +217,58 @@ async function loadQueue() {
         qList.appendChild(d);
     });
 }
-document.getElementById('qRefresh').onclick = loadQueue;
+document.getElementById('qRefresh').onclick = () => { loadQueue(); loadTTSHistory(); };
+document.getElementById('qSkip').onclick = async () => {
+    await fetch('/api/tts/skip', { method:'POST' });
+    loadTTSHistory();
+};
+
+// TTS history (spoken / skipped) with replay
+async function loadTTSHistory() {
+    const list = document.getElementById('qHistory');
+    const page = await apiGet('/api/tts/history?status=spoken,skipped&limit=10');
+    list.innerHTML = page.items.length ? '' : '<div class="item"><em>Nothing yet</em></div>';
+    page.items.forEach(it => {
+        const d = document.createElement('div'); d.className='item';
+        d.innerHTML = `<div>${it.text}<br/>
+      <small class="mono">${it.donor||'Anonymous'}</small> |
+      <small class="mono">${it.status}${it.replays ? `, replayed ${it.replays}×` : ''}</small></div>`;
+        const btn = document.createElement('button');
+        btn.className = 'secondary';
+        btn.textContent = 'Replay';
+        btn.onclick = async () => { await fetch(`/api/tts/replay?id=${it.id}`, { method:'POST' }); loadTTSHistory(); };
+        d.appendChild(btn);
+        list.appendChild(d);
+    });
+}
+
+// Auto-approve kill switch
+const qManualOnly = document.getElementById('qManualOnly');
+async function loadRules() {
+    const cfg = await apiGet('/api/tts/rules');
+    qManualOnly.checked = !!cfg.manual_only;
+}
+qManualOnly.onchange = async () => {
+    await fetch(`/api/tts/manual-only?enabled=${qManualOnly.checked}`, { method:'POST' });
+    loadRules();
+};
+// Voice catalog
+async function loadVoices() {
+    const { voices } = await apiGet('/api/tts/voices');
+    const sel = document.getElementById('qVoice');
+    sel.innerHTML = '<option value="">Tier default</option>';
+    voices.forEach(v => {
+        const o = document.createElement('option');
+        o.value = v.id;
+        o.textContent = v.min_amount_cents > 0 ? `${v.name} ($${(v.min_amount_cents/100).toFixed(2)}+)` : v.name;
+        sel.appendChild(o);
+    });
+}
+document.getElementById('qPreview').onclick = async () => {
+    const text = document.getElementById('qText').value||''; if(!text) return;
+    const { spoken } = await apiGet(`/api/tts/normalize/preview?text=${encodeURIComponent(text)}`);
+    document.getElementById('qSpoken').textContent = `Spoken as: ${spoken}`;
+};
 document.getElementById('qSubmit').onclick = async () => {
     const text = document.getElementById('qText').value||''; if(!text) return;
     const voice = document.getElementById('qVoice').value||'';
@@ -137,23 +280,145 @@ document.getElementById('qSubmit').onclick = async () => {
 };
 
 // Requests
+
+// Boards come from the catalog; the filter narrows every request list
+let BOARDS = {};
+const boardLabel = id => (BOARDS[id] && BOARDS[id].name) || id || '(no board)';
+const boardFilter = () => encodeURIComponent(document.getElementById('rqBoardFilter').value || '');
+async function loadBoards() {
+    const boards = await apiGet('/api/request/boards');
+    const pick = document.getElementById('rqBoard');
+    const filter = document.getElementById('rqBoardFilter');
+    const chosen = [pick.value, filter.value];
+    pick.innerHTML = '';
+    filter.innerHTML = '<option value="">All boards</option>';
+    BOARDS = {};
+    boards.forEach(b => {
+        BOARDS[b.id] = b;
+        const price = b.price_cents > 0 ? ` $${(b.price_cents/100).toFixed(2)}` : '';
+        pick.add(new Option(`${b.name}${price}${b.phone_required ? ' (phone)' : ''}`, b.id));
+        filter.add(new Option(`${b.name} (${b.pending} pending, ${b.active}/${b.max_active || '∞'} active)`, b.id));
+    });
+    if (chosen[0]) pick.value = chosen[0];
+    filter.value = chosen[1];
+}
+document.getElementById('rqBoardFilter').onchange = () => { loadHeldRequests(); loadRequestQueue(); loadActiveRequests(); loadCallLog(); };
+
+// operatorFetch sends the operator's token (asked for once per browser
+// session, and forgotten if the server refuses it). It resolves to
+// undefined if no token was given.
+async function operatorFetch(url, opts = {}) {
+    let token = sessionStorage.getItem('operatorToken');
+    if (!token) {
+        token = prompt('Operator token') || '';
+        if (!token) return;
+        sessionStorage.setItem('operatorToken', token);
+    }
+    const res = await fetch(url, { ...opts, headers:{ ...(opts.headers||{}), Authorization:`Bearer ${token}` } });
+    if (res.status === 401 || res.status === 403) sessionStorage.removeItem('operatorToken');
+    return res;
+}
+
+// revealButton fetches the full number with the operator's token; the
+// server audits every reveal.
+function revealButton(it, row) {
+    const btn = document.createElement('button');
+    btn.className = 'secondary';
+    btn.textContent = 'Reveal';
+    btn.onclick = async () => {
+        const res = await operatorFetch(`/api/request/reveal?id=${it.id}`, { method:'POST' });
+        if (!res) return;
+        const body = await res.json();
+        if (!res.ok) {
+            alert(body.error ? body.error.message : 'Reveal failed');
+            return;
+        }
+        row.querySelector('small.mono').textContent = `Phone: ${body.data.phone}`;
+        btn.remove();
+    };
+    return btn;
+}
+
+// Requests on paid boards wait here until a donation quoting their
+// reference (or from the named donor) covers the price
+async function loadHeldRequests() {
+    const list = document.getElementById('rqHeld');
+    const items = await apiGet(`/api/request/held?board=${boardFilter()}`);
+    list.innerHTML = items.length ? '' : '<div class="item"><em>None</em></div>';
+    items.forEach(it => {
+        const d = document.createElement('div'); d.className='item';
+        d.innerHTML = `<div><strong>${boardLabel(it.board)} ${it.note? '—'+it.note : ''}</strong>
+      <span class="tier">${it.payment_ref}</span><br/>
+      <small class="mono">$${(it.price_cents/100).toFixed(2)}${it.donor ? ' from '+it.donor : ''} | ${it.masked_phone||'(no phone)'}</small></div>`;
+        const btns = document.createElement('div'); btns.className='btns';
+        [['Mark paid','mark-paid'],['Cancel','reject']].forEach(([label, act], i) => {
+            const btn = document.createElement('button');
+            btn.textContent = label;
+            if (i > 0) btn.className = 'secondary';
+            btn.onclick = async () => {
+                await fetch(`/api/request/${act}?id=${it.id}`, { method:'POST' });
+                loadHeldRequests(); loadRequestQueue(); loadCallLog();
+            };
+            btns.appendChild(btn);
+        });
+        d.appendChild(btns);
+        list.appendChild(d);
+    });
+}
+
 async function loadRequestQueue() {
     const rqList = document.getElementById('rqList');
-    const items = await fetch('/api/request/queue').then(r => r.json());
+    const items = await apiGet(`/api/request/queue?board=${boardFilter()}`);
     rqList.innerHTML = items.length ? '' : '<div class="item"><em>None pending</em></div>';
     items.forEach(it => {
         const d = document.createElement('div'); d.className='item';
-        d.innerHTML = `<div><strong>${it.board||( '(no board)')} ${it.note? '—'+it.note : ''}</strong><br/>
-      <small class="mono">Phone: ${it.phone||'(none)'}</small> |
-      <small class="mono">Masked: ${it.masked_phone||'(none)'}</small></div>`;
+        const repeats = it.duplicates ? ` <span class="tier">+${it.duplicates} repeat${it.duplicates>1?'s':''}</span>` : '';
+        const marks = `${it.pinned ? ' 📌' : ''}${it.flagged ? ' 🚩' : ''}`;
+        d.innerHTML = `<div><strong>${boardLabel(it.board)} ${it.note? '—'+it.note : ''}</strong>${marks}${repeats}<br/>
+      <small class="mono">Phone: ${it.masked_phone||'(none)'}</small></div>`;
         const btns = document.createElement('div'); btns.className='btns';
+        // queue order and edits; refused moves (end of the board's queue) are ignored
+        const queueBtn = (label, title, run) => {
+            const btn = document.createElement('button');
+            btn.className = 'secondary'; btn.textContent = label; btn.title = title;
+            btn.onclick = async () => {
+                const res = await run();
+                if (res && !res.ok && res.status !== 409) { const body = await res.json(); alert(body.error.message); }
+                loadRequestQueue();
+            };
+            btns.appendChild(btn);
+        };
+        queueBtn('↑', 'Move up', () => fetch(`/api/request/move?id=${it.id}&dir=up`, { method:'POST' }));
+        queueBtn('↓', 'Move down', () => fetch(`/api/request/move?id=${it.id}&dir=down`, { method:'POST' }));
+        queueBtn(it.pinned ? 'Unpin' : 'Pin', 'Pin to the top', () => fetch(`/api/request/pin?id=${it.id}&pinned=${!it.pinned}`, { method:'POST' }));
+        queueBtn(it.flagged ? 'Unflag' : 'Flag', 'Flag for review', () => fetch(`/api/request/edit?id=${it.id}`, { method:'POST', body: JSON.stringify({ flagged: !it.flagged }) }));
+        queueBtn('Edit', 'Edit note and board', () => {
+            const note = prompt('Note', it.note || ''); if (note === null) return;
+            const board = prompt(`Board (${Object.keys(BOARDS).join(', ')})`, it.board); if (board === null) return;
+            return fetch(`/api/request/edit?id=${it.id}`, { method:'POST', body: JSON.stringify({ note, board }) });
+        });
+        if (it.has_phone) btns.appendChild(revealButton(it, d));
+        if (it.has_phone) {
+            const block = document.createElement('button');
+            block.className = 'secondary';
+            block.textContent = 'Block number';
+            block.onclick = async () => {
+                const reason = prompt('Reason for blocking this number (optional)');
+                if (reason === null) return;
+                await fetch('/api/request/blocklist', { method:'POST', body: JSON.stringify({ request_id: it.id, reason }) });
+                await fetch(`/api/request/reject?id=${it.id}&note=${encodeURIComponent('blocked')}`, { method:'POST' });
+                loadRequestQueue(); loadCallLog(); loadBlocklist();
+            };
+            btns.appendChild(block);
+        }
         ['approve','reject'].forEach((act,i) => {
             const btn = document.createElement('button');
             btn.textContent = act.charAt(0).toUpperCase()+act.slice(1);
             if(act==='reject') btn.className='secondary';
             btn.onclick = async () => {
-                await fetch(`/api/request/${act}?id=${it.id}`,{ method:'POST' });
-                loadRequestQueue(); loadActiveRequests();
+                const res = await fetch(`/api/request/${act}?id=${it.id}`,{ method:'POST' });
+                if (!res.ok) { const body = await res.json(); alert(body.error.message); } // e.g. board full
+                loadBoards(); loadRequestQueue(); loadActiveRequests(); loadCallLog();
             };
             btns.appendChild(btn);
         });
@@ -163,31 +428,146 @@ async function loadRequestQueue() {
 }
 async function loadActiveRequests() {
     const rqActive = document.getElementById('rqActive');
-    const items = await fetch('/api/request/active').then(r => r.json());
+    const items = await apiGet(`/api/request/active?board=${boardFilter()}`);
     rqActive.innerHTML = items.length ? '' : '<div class="item"><em>None</em></div>';
     items.forEach(it => {
         const d = document.createElement('div'); d.className='item';
-        d.innerHTML = `<div><strong>${it.board||( '(no board)')} ${it.note? '—'+it.note : ''}</strong><br/>
-      <small class="mono">Masked: ${it.masked_phone||'(none)'}</small></div>`;
+        d.innerHTML = `<div><strong>${boardLabel(it.board)} ${it.note? '—'+it.note : ''}</strong><br/>
+      <small class="mono">Phone: ${it.masked_phone||'(none)'}</small> |
+      <small class="mono">${it.status}</small></div>`;
+        const btns = document.createElement('div'); btns.className='btns';
+        if (it.has_phone) btns.appendChild(revealButton(it, d));
+        if (it.status === 'approved') {
+            const edit = document.createElement('button');
+            edit.className = 'secondary'; edit.textContent = 'Edit'; edit.title = 'Edit note and board';
+            edit.onclick = async () => {
+                const note = prompt('Note', it.note || ''); if (note === null) return;
+                const board = prompt(`Board (${Object.keys(BOARDS).join(', ')})`, it.board); if (board === null) return;
+                const res = await fetch(`/api/request/edit?id=${it.id}`, { method:'POST', body: JSON.stringify({ note, board }) });
+                if (!res.ok) { const body = await res.json(); alert(body.error.message); }
+                loadActiveRequests();
+            };
+            btns.appendChild(edit);
+        }
+        // approved → start call; in_call → record the outcome
+        const actions = it.status === 'in_call'
+            ? [['Completed','complete?outcome=completed'],['Failed','complete?outcome=failed'],['No answer','complete?outcome=no_answer']]
+            : [['Start call','start'],['Cancel','reject']];
+        actions.forEach(([label, path], i) => {
+            const btn = document.createElement('button');
+            btn.textContent = label;
+            if (i > 0) btn.className = 'secondary';
+            btn.onclick = async () => {
+                const sep = path.includes('?') ? '&' : '?';
+                const note = it.status === 'in_call' ? (prompt('Outcome note (optional)') || '') : '';
+                await fetch(`/api/request/${path}${sep}id=${it.id}&note=${encodeURIComponent(note)}`, { method:'POST' });
+                loadActiveRequests(); loadCallLog();
+            };
+            btns.appendChild(btn);
+        });
+        d.appendChild(btns);
+        rqActive.appendChild(d);
+    });
+}
+
+// Finished requests, searchable
+async function loadCallLog() {
+    const list = document.getElementById('rqLog');
+    const q = encodeURIComponent(document.getElementById('rqLogSearch').value || '');
+    const page = await apiGet(`/api/request/log?limit=20&q=${q}&board=${boardFilter()}`);
+    list.innerHTML = page.items.length ? '' : '<div class="item"><em>No finished calls</em></div>';
+    page.items.forEach(it => {
+        const last = (it.history || []).slice(-1)[0] || {};
+        const d = document.createElement('div'); d.className='item';
+        d.innerHTML = `<div><strong>${boardLabel(it.board)}</strong> <small class="mono">${it.status}</small><br/>
+      <small class="mono">${it.masked_phone||''} ${it.outcome_note ? '— '+it.outcome_note : ''}</small>
+      ${it.auto_reason ? '<small class="mono">(auto)</small>' : ''}
+      <small class="mono">by ${last.operator||'?'}</small></div>`;
+        list.appendChild(d);
+    });
+}
+document.getElementById('rqLogSearch').oninput = loadCallLog;
+
+// Blocked numbers and boards; numbers are added from a pending request
+async function loadBlocklist() {
+    const list = document.getElementById('rqBlocklist');
+    const entries = await apiGet('/api/request/blocklist');
+    list.innerHTML = entries.length ? '' : '<div class="item"><em>Nothing blocked</em></div>';
+    entries.forEach(e => {
+        const d = document.createElement('div'); d.className='item';
+        d.innerHTML = `<div><strong>${e.label}</strong> <small class="mono">${e.kind}</small><br/>
+      <small class="mono">${e.reason||''} by ${e.added_by}</small></div>`;
         const btn = document.createElement('button');
-        btn.textContent='Complete';
-        btn.onclick = async () => { await fetch(`/api/request/complete?id=${it.id}`,{ method:'POST'}); loadActiveRequests(); };
+        btn.className = 'secondary';
+        btn.textContent = 'Unblock';
+        btn.onclick = async () => {
+            await fetch(`/api/request/blocklist/remove?id=${e.id}`, { method:'POST' });
+            loadBlocklist();
+        };
         d.appendChild(btn);
-        rqActive.appendChild(d);
+        list.appendChild(d);
     });
 }
+document.getElementById('rqBlockAdd').onclick = async () => {
+    const board = document.getElementById('rqBlockBoard').value||''; if(!board) return;
+    const res = await fetch('/api/request/blocklist', { method:'POST', body: JSON.stringify({ board }) });
+    if (!res.ok) { const body = await res.json(); alert(body.error.message); return; }
+    document.getElementById('rqBlockBoard').value = '';
+    loadBlocklist();
+};
 document.getElementById('rqRefresh').onclick = loadRequestQueue;
+document.getElementById('rqBulkReject').onclick = async () => {
+    const board = document.getElementById('rqBoardFilter').value || '';
+    const older_than_minutes = parseInt(document.getElementById('rqBulkAge').value || '0', 10);
+    const flagged = document.getElementById('rqBulkFlagged').checked;
+    if (!confirm(`Reject pending requests on ${board ? boardLabel(board) : 'all boards'}${older_than_minutes ? `, older than ${older_than_minutes} min` : ''}${flagged ? ', flagged' : ''}?`)) return;
+    const res = await fetch('/api/request/bulk-reject', { method:'POST', body: JSON.stringify({ board, older_than_minutes, flagged }) });
+    const body = await res.json();
+    if (!res.ok) { alert(body.error.message); return; }
+    alert(`Rejected ${body.data.rejected.length} request(s)`);
+    loadBoards(); loadRequestQueue(); loadCallLog();
+};
+// Numbers without a +country code are read as local to the chosen region
+async function loadRegions() {
+    const { default: def, regions } = await apiGet('/api/request/regions');
+    const sel = document.getElementById('rqRegion');
+    sel.innerHTML = '';
+    regions.forEach(rg => {
+        const o = document.createElement('option');
+        o.value = rg.code;
+        o.textContent = `${rg.name} (+${rg.country_code})`;
+        o.selected = rg.code === def;
+        sel.appendChild(o);
+    });
+}
 document.getElementById('rqSubmit').onclick = async () => {
     const board = document.getElementById('rqBoard').value||'';
     const phone = document.getElementById('rqPhone').value||'';
+    const region = document.getElementById('rqRegion').value||'';
     const note = document.getElementById('rqNote').value||'';
-    await fetch(`/api/request/submit?board=${encodeURIComponent(board)}&phone=${encodeURIComponent(phone)}&note=${encodeURIComponent(note)}`);
-    loadRequestQueue(); loadActiveRequests();
+    const donor = document.getElementById('rqDonor').value||'';
+    const res = await fetch(`/api/request/submit?board=${encodeURIComponent(board)}&phone=${encodeURIComponent(phone)}&region=${encodeURIComponent(region)}&note=${encodeURIComponent(note)}&donor=${encodeURIComponent(donor)}`);
+    const body = await res.json();
+    if (!res.ok) {
+        alert(body.error ? body.error.message : 'Submit failed');
+        return;
+    }
+    const receipt = document.getElementById('rqReceipt');
+    receipt.innerHTML = `Tracking: <a href="${body.data.status_page}" target="_blank">${body.data.status_page}</a>`;
+    if (body.data.status === 'awaiting_payment') {
+        alert(`Awaiting payment: donate $${(body.data.price_cents/100).toFixed(2)} with ${body.data.payment_ref} in the message`);
+    }
+    document.getElementById('rqPhone').value = '';
+    loadBoards(); loadHeldRequests(); loadRequestQueue(); loadActiveRequests();
 };
 
 // Init
 refreshClients();
 loadCatalog();
 loadQueue();
-loadRequestQueue();
-loadActiveRequests();
+loadTTSHistory();
+loadRules();
+loadVoices();
+loadRegions();
+loadBoards().then(() => { loadHeldRequests(); loadRequestQueue(); loadActiveRequests(); loadCallLog(); });
+loadBlocklist();
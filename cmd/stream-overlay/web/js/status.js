@@ -0,0 +1,45 @@
+// Follows one request by its tracking token (?token=), polling the public
+// status endpoint; it never sees the caller's details
+const token = new URLSearchParams(location.search).get('token') || '';
+const card = document.getElementById('card');
+const elStatus = document.getElementById('status');
+const elPosition = document.getElementById('position');
+const elETA = document.getElementById('eta');
+
+const LABELS = {
+    awaiting_payment: ['Waiting for payment', 'pending'],
+    pending: ['In the queue', 'pending'],
+    approved: ['Approved — up soon', 'approved'],
+    in_call: ['On the call now!', 'in_call'],
+    completed: ['Call completed', 'done'],
+    failed: ['Call failed', 'closed'],
+    no_answer: ['No answer', 'closed'],
+    rejected: ['Not accepted', 'closed'],
+    expired: ['Expired', 'closed'],
+    merged: ['Already requested', 'pending'],
+};
+const FINAL = ['completed', 'failed', 'no_answer', 'rejected', 'expired'];
+
+function fmtWait(s) {
+    if (s < 60) return 'less than a minute';
+    const m = Math.round(s / 60);
+    return m < 60 ? `about ${m} min` : `about ${Math.floor(m / 60)} h ${m % 60} min`;
+}
+
+async function refresh() {
+    if (!token) { elStatus.textContent = 'No tracking token'; return; }
+    const body = await fetch(`/api/request/status/${encodeURIComponent(token)}`).then(r => r.json()).catch(() => null);
+    if (!body || body.error) {
+        elStatus.textContent = body && body.error.code === 'not_found' ? 'Request not found' : 'Status unavailable';
+        setTimeout(refresh, 30000);
+        return;
+    }
+    const st = body.data;
+    const [label, cls] = LABELS[st.status] || [st.status, ''];
+    card.className = `status-card ${cls}`;
+    elStatus.textContent = label;
+    elPosition.textContent = st.position ? `Position ${st.position} in line` : '';
+    elETA.textContent = st.eta_seconds != null ? `Estimated wait: ${fmtWait(st.eta_seconds)}` : '';
+    if (!FINAL.includes(st.status)) setTimeout(refresh, 10000);
+}
+refresh();
@@ -0,0 +1,103 @@
+package abilities
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/catalog"
+	"github.com/dtorres47/stream-overlay/internal/events"
+	"github.com/dtorres47/stream-overlay/internal/logging"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
+	"github.com/dtorres47/stream-overlay/internal/ws"
+	"github.com/go-chi/chi/v5"
+)
+
+var logger = logging.For("abilities")
+
+var mFired = metrics.NewCounter("overlay_ability_fires_total", "Abilities fired, by ability.", "ability")
+
+// defaultCooldown matches the overlay's fallback for abilities without one.
+const defaultCooldown = 3 * time.Second
+
+var (
+	fireMu    sync.Mutex
+	lastFired = map[string]time.Time{}
+)
+
+// RegisterRoutes mounts the /api/ability/* endpoints.
+func RegisterRoutes(r chi.Router) {
+	r.Post("/api/ability/fire", handleFire)
+}
+
+// CooldownError is a fire refused because the ability played too recently.
+type CooldownError struct {
+	ID        string
+	Remaining time.Duration
+}
+
+func (e *CooldownError) Error() string { return "ability " + e.ID + " is cooling down" }
+
+// ErrUnknown is returned by Fire for an ability not in the catalog.
+var ErrUnknown = errors.New("unknown ability")
+
+// Fire plays ability id on the overlay and publishes it on the event bus,
+// unless it is still within its cooldown.
+func Fire(ctx context.Context, id string) (catalog.Ability, error) {
+	return fire(ctx, id, false)
+}
+
+// FireReward plays ability id as something a viewer has already earned
+// (a completed quest's reward), so its cooldown doesn't apply. It still
+// starts a new cooldown for ordinary fires.
+func FireReward(ctx context.Context, id string) (catalog.Ability, error) {
+	return fire(ctx, id, true)
+}
+
+func fire(ctx context.Context, id string, force bool) (catalog.Ability, error) {
+	a, ok := catalog.GetAbility(id)
+	if !ok {
+		return catalog.Ability{}, ErrUnknown
+	}
+	cooldown := time.Duration(a.CooldownMs) * time.Millisecond
+	if cooldown <= 0 {
+		cooldown = defaultCooldown
+	}
+
+	fireMu.Lock()
+	now := time.Now()
+	if since := now.Sub(lastFired[id]); since < cooldown && !force {
+		fireMu.Unlock()
+		return a, &CooldownError{ID: id, Remaining: cooldown - since}
+	}
+	lastFired[id] = now
+	fireMu.Unlock()
+
+	ws.Broadcast(ws.WSMsg{Type: "ABILITY_FIRE", Data: a})
+	mFired.Inc(id)
+	logger.InfoContext(ctx, "ability fired", "id", id, "reward", force)
+	events.Publish(ctx, events.Event{Kind: events.AbilityFired, Subject: id})
+	return a, nil
+}
+
+// handleFire plays an ability on the overlay: POST /api/ability/fire?id=
+// Firing it again within its cooldown gets a 429.
+func handleFire(w http.ResponseWriter, r *http.Request) {
+	id, ok := api.QueryString(w, r, "id")
+	if !ok {
+		return
+	}
+	a, err := Fire(r.Context(), id)
+	var cd *CooldownError
+	switch {
+	case errors.Is(err, ErrUnknown):
+		api.NotFound(w, "ability id")
+	case errors.As(err, &cd):
+		api.TooMany(w, "id", cd.Remaining, cd.Error())
+	default:
+		api.OK(w, a)
+	}
+}
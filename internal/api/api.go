@@ -0,0 +1,170 @@
+// Package api holds the helpers every HTTP handler uses to talk JSON, plus
+// the embedded OpenAPI document describing the public API.
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Error codes returned in ErrorDetail.Code.
+const (
+	CodeBadRequest   = "bad_request"       // 400: malformed input (e.g. id=abc)
+	CodeUnauthorized = "unauthorized"      // 401: missing or unknown operator token
+	CodeForbidden    = "forbidden"         // 403: operator lacks the required role
+	CodeNotFound     = "not_found"         // 404: no such item
+	CodeConflict     = "conflict"          // 409: item is in the wrong state
+	CodeValidation   = "validation_failed" // 422: well-formed but unacceptable
+	CodeRateLimit    = "rate_limited"      // 429: try again after Retry-After
+	CodeInternal     = "internal"          // 500
+)
+
+// DataBody is the JSON shape of every 2xx response.
+type DataBody struct {
+	Data any `json:"data"`
+}
+
+// ErrorBody is the JSON shape of every non-2xx response.
+type ErrorBody struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes what went wrong. Field names the offending query
+// parameter or body field, when there is one.
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+}
+
+// JSON writes v as a JSON response with the given status.
+func JSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// OK writes {"data": v} with status 200.
+func OK(w http.ResponseWriter, v any) { JSON(w, http.StatusOK, DataBody{Data: v}) }
+
+// Created writes {"data": v} with status 201.
+func Created(w http.ResponseWriter, v any) { JSON(w, http.StatusCreated, DataBody{Data: v}) }
+
+// Error writes a JSON error body; use it instead of http.Error.
+func Error(w http.ResponseWriter, status int, code, message string) {
+	JSON(w, status, ErrorBody{Error: ErrorDetail{Code: code, Message: message}})
+}
+
+// FieldError is Error with the offending field named.
+func FieldError(w http.ResponseWriter, status int, code, field, message string) {
+	JSON(w, status, ErrorBody{Error: ErrorDetail{Code: code, Message: message, Field: field}})
+}
+
+// NotFound writes a 404 for the named thing.
+func NotFound(w http.ResponseWriter, what string) {
+	Error(w, http.StatusNotFound, CodeNotFound, "unknown "+what)
+}
+
+// Conflict writes a 409 for an illegal state transition.
+func Conflict(w http.ResponseWriter, message string) {
+	Error(w, http.StatusConflict, CodeConflict, message)
+}
+
+// Invalid writes a 422 for a field that parsed but failed validation.
+func Invalid(w http.ResponseWriter, field, message string) {
+	FieldError(w, http.StatusUnprocessableEntity, CodeValidation, field, message)
+}
+
+// TooMany writes a 429 with a Retry-After header, rounded up to whole seconds.
+func TooMany(w http.ResponseWriter, field string, retryAfter time.Duration, message string) {
+	secs := int((retryAfter + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	FieldError(w, http.StatusTooManyRequests, CodeRateLimit, field, message)
+}
+
+// QueryInt parses a required integer query parameter. On failure it writes
+// a 400 naming the parameter and returns ok=false.
+func QueryInt(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		FieldError(w, http.StatusBadRequest, CodeBadRequest, name, "missing ?"+name+"=")
+		return 0, false
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		FieldError(w, http.StatusBadRequest, CodeBadRequest, name, name+" must be an integer")
+		return 0, false
+	}
+	return n, true
+}
+
+// QueryInt64 parses an optional int64 query parameter, defaulting to def
+// when absent. On a malformed value it writes a 400 and returns ok=false.
+func QueryInt64(w http.ResponseWriter, r *http.Request, name string, def int64) (int64, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, true
+	}
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		FieldError(w, http.StatusBadRequest, CodeBadRequest, name, name+" must be an integer")
+		return 0, false
+	}
+	return n, true
+}
+
+// Page is one slice of a longer list plus where it sits in that list.
+type Page struct {
+	Items  any `json:"items"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// QueryPage reads ?limit= and ?offset=, defaulting limit to def and capping
+// it at max. It writes a 400 and returns ok=false on bad values.
+func QueryPage(w http.ResponseWriter, r *http.Request, def, max int) (limit, offset int, ok bool) {
+	l, ok := QueryInt64(w, r, "limit", int64(def))
+	if !ok {
+		return 0, 0, false
+	}
+	o, ok := QueryInt64(w, r, "offset", 0)
+	if !ok {
+		return 0, 0, false
+	}
+	if l < 1 || o < 0 {
+		FieldError(w, http.StatusBadRequest, CodeBadRequest, "limit", "limit must be positive and offset must not be negative")
+		return 0, 0, false
+	}
+	if l > int64(max) {
+		l = int64(max)
+	}
+	return int(l), int(o), true
+}
+
+// QueryString returns a required string query parameter, writing a 400 when
+// it is missing.
+func QueryString(w http.ResponseWriter, r *http.Request, name string) (string, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		FieldError(w, http.StatusBadRequest, CodeBadRequest, name, "missing ?"+name+"=")
+		return "", false
+	}
+	return v, true
+}
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// RegisterRoutes serves the OpenAPI document at /api/openapi.json.
+func RegisterRoutes(r chi.Router) {
+	r.Get("/api/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	})
+}
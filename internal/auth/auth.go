@@ -0,0 +1,126 @@
+// Package auth identifies operators by bearer token and gates sensitive
+// endpoints on the roles granted to them.
+//
+// Operators are configured with OPERATOR_TOKENS, a comma-separated list of
+// name:token:roles entries where roles are separated by "|", e.g.
+//
+//	OPERATOR_TOKENS="dana:9f2c…:reveal_phone|record_donations,alex:77ab…"
+//
+// With no operators configured every gated endpoint answers 401.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/logging"
+)
+
+var logger = logging.For("auth")
+
+// Operator is an authenticated panel user.
+type Operator struct {
+	Name  string
+	Roles map[string]bool
+}
+
+// Has reports whether the operator was granted role.
+func (o Operator) Has(role string) bool { return o.Roles[role] }
+
+type operatorEntry struct {
+	hash [sha256.Size]byte // of the token, so lookups compare fixed-size values
+	op   Operator
+}
+
+var (
+	mu        sync.RWMutex
+	operators []operatorEntry
+)
+
+// Setup reads OPERATOR_TOKENS. Malformed entries are logged and skipped.
+func Setup() {
+	var list []operatorEntry
+	for _, entry := range strings.Split(os.Getenv("OPERATOR_TOKENS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			logger.Warn("ignoring malformed OPERATOR_TOKENS entry; want name:token[:roles]")
+			continue
+		}
+		op := Operator{Name: parts[0], Roles: map[string]bool{}}
+		if len(parts) == 3 {
+			for _, role := range strings.Split(parts[2], "|") {
+				if role = strings.TrimSpace(role); role != "" {
+					op.Roles[role] = true
+				}
+			}
+		}
+		list = append(list, operatorEntry{hash: sha256.Sum256([]byte(parts[1])), op: op})
+	}
+	mu.Lock()
+	operators = list
+	mu.Unlock()
+	logger.Info("operators configured", "count", len(list))
+}
+
+// Identify returns the operator whose token is in the request's
+// Authorization: Bearer header.
+func Identify(r *http.Request) (Operator, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return Operator{}, false
+	}
+	h := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	mu.RLock()
+	defer mu.RUnlock()
+	found := -1
+	for i := range operators {
+		// check every entry so timing doesn't reveal which one matched
+		if subtle.ConstantTimeCompare(h[:], operators[i].hash[:]) == 1 {
+			found = i
+		}
+	}
+	if found < 0 {
+		return Operator{}, false
+	}
+	return operators[found].op, true
+}
+
+type ctxKey struct{}
+
+// FromContext returns the operator stored by Require.
+func FromContext(ctx context.Context) (Operator, bool) {
+	op, ok := ctx.Value(ctxKey{}).(Operator)
+	return op, ok
+}
+
+// Require lets a request through only if it carries a valid token whose
+// operator holds role, answering 401 or 403 otherwise. The operator is
+// available to the handler through FromContext.
+func Require(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op, ok := Identify(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="stream-overlay"`)
+				api.Error(w, http.StatusUnauthorized, api.CodeUnauthorized, "a valid operator token is required")
+				return
+			}
+			if !op.Has(role) {
+				logger.WarnContext(r.Context(), "operator lacks role", "operator", op.Name, "role", role, "path", r.URL.Path)
+				api.Error(w, http.StatusForbidden, api.CodeForbidden, "operator "+op.Name+" lacks the "+role+" role")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, op)))
+		})
+	}
+}
@@ -0,0 +1,16 @@
+// Package buildinfo carries version metadata stamped in at link time:
+//
+//	go build -ldflags "-X github.com/dtorres47/stream-overlay/internal/buildinfo.Version=v1.2.3 \
+//	  -X github.com/dtorres47/stream-overlay/internal/buildinfo.Commit=$(git rev-parse --short HEAD)"
+package buildinfo
+
+import "time"
+
+var (
+	Version = "dev"
+	Commit  = "unknown"
+	BuiltAt = "" // RFC 3339, optional
+)
+
+// StartedAt is when the process started; used for uptime reporting.
+var StartedAt = time.Now()
@@ -2,12 +2,20 @@ package catalog
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/events"
+	"github.com/dtorres47/stream-overlay/internal/logging"
 	"github.com/go-chi/chi/v5"
 )
 
+var logger = logging.For("catalog")
+
 type Ability struct {
 	ID         string  `json:"id"`
 	Name       string  `json:"name"`
@@ -19,28 +27,122 @@ type Ability struct {
 }
 
 type Quest struct {
-	ID         string `json:"id"`
-	Name       string `json:"name"`
-	PriceCents int64  `json:"price_cents"`
-	IconURL    string `json:"icon_url"`
-	Target     int    `json:"target"`
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	PriceCents int64     `json:"price_cents"`
+	IconURL    string    `json:"icon_url"`
+	Target     int       `json:"target"`
+	Triggers   []Trigger `json:"triggers,omitempty"`
+	Overfund   string    `json:"overfund,omitempty"` // OverfundRaise (default) or OverfundRepeat
+
+	Celebration   Celebration `json:"celebration"`
+	RewardAbility string      `json:"reward_ability,omitempty"` // ability fired when the quest completes
+
+	DurationSeconds int `json:"duration_seconds,omitempty"` // time allowed for each run; 0 = no deadline
+}
+
+// Celebration is how the overlay marks a quest's completion. Empty fields
+// fall back to the overlay's defaults.
+type Celebration struct {
+	SoundURL  string `json:"sound_url,omitempty"`
+	Animation string `json:"animation,omitempty"` // CSS animation played on the quest
+	TTS       string `json:"tts,omitempty"`       // line the overlay speaks; {name} is the quest's name
+}
+
+// What donations toward an already-funded quest buy, one PriceCents at a
+// time.
+const (
+	OverfundRaise  = "raise"  // the active quest's target grows by Target
+	OverfundRepeat = "repeat" // the quest runs again once it is done
+)
+
+// Trigger advances an active quest when an event happens, e.g.
+// {"on": "ability", "id": "goat"} or {"on": "donation", "per_dollar": true}.
+type Trigger struct {
+	On        string `json:"on"`                   // event kind: ability, request_completed or donation
+	ID        string `json:"id,omitempty"`         // ability or board ID to match; empty matches any; not allowed on donation
+	Step      int    `json:"step,omitempty"`       // progress per event (default 1)
+	PerDollar bool   `json:"per_dollar,omitempty"` // donations: progress per whole dollar instead
+}
+
+// validTriggers drops triggers for unknown event kinds, and donation
+// triggers with an ID (donations have nothing to match it against), and
+// fills defaults.
+func validTriggers(q Quest) []Trigger {
+	var out []Trigger
+	for _, t := range q.Triggers {
+		switch t.On {
+		case events.AbilityFired, events.RequestCompleted, events.Donation:
+		default:
+			logger.Warn("quest trigger ignored: unknown event", "quest", q.ID, "on", t.On)
+			continue
+		}
+		if t.On == events.Donation && t.ID != "" {
+			logger.Warn("quest trigger ignored: donation triggers can't match an id", "quest", q.ID, "id", t.ID)
+			continue
+		}
+		if t.Step <= 0 {
+			t.Step = 1
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// Board is a kind of call request with its own queue and rules.
+type Board struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	PriceCents    int64      `json:"price_cents"`
+	MaxActive     int        `json:"max_active"` // approved or on a call at once; 0 = no limit
+	PhoneRequired bool       `json:"phone_required"`
+	Style         BoardStyle `json:"style"`
+}
+
+// BoardStyle is how a board's section looks on the overlay.
+type BoardStyle struct {
+	Color string `json:"color,omitempty"` // CSS colour for the heading and entries
+	Icon  string `json:"icon,omitempty"`  // emoji or short text shown before entries
 }
 
 var (
 	abilities = map[string]Ability{}
 	quests    = map[string]Quest{}
+	boards    = map[string]Board{}
+)
+
+// LoadStatus describes the outcome of the most recent LoadCatalog call.
+type LoadStatus struct {
+	Loaded    bool   `json:"loaded"`
+	Error     string `json:"error,omitempty"`
+	Abilities int    `json:"abilities"`
+	Quests    int    `json:"quests"`
+	Boards    int    `json:"boards"`
+	LoadedAt  int64  `json:"loaded_at_unix,omitempty"`
+}
+
+var (
+	statusMu   sync.Mutex
+	loadStatus LoadStatus
 )
 
 type catalogFile struct {
 	Abilities []Ability `json:"abilities"`
 	Quests    []Quest   `json:"quests"`
+	Boards    []Board   `json:"boards"`
 }
 
-// LoadCatalog unmarshals the embedded catalog.json into memory.
-func LoadCatalog() {
+// LoadCatalog unmarshals the embedded catalog.json into memory. On failure
+// the previous catalog (empty at startup) is kept and Status reports the error.
+func LoadCatalog() error {
 	var cf catalogFile
 	if err := json.Unmarshal(embeddedCatalog, &cf); err != nil {
-		log.Fatalf("failed to parse embedded catalog: %v", err)
+		logger.Error("failed to parse embedded catalog", "err", err)
+		statusMu.Lock()
+		loadStatus.Loaded = false
+		loadStatus.Error = err.Error()
+		statusMu.Unlock()
+		return err
 	}
 
 	abilities = make(map[string]Ability, len(cf.Abilities))
@@ -53,10 +155,52 @@ func LoadCatalog() {
 		if q.Target <= 0 {
 			q.Target = 1
 		}
+		q.Triggers = validTriggers(q)
+		switch q.Overfund {
+		case OverfundRaise, OverfundRepeat:
+		case "":
+			q.Overfund = OverfundRaise
+		default:
+			logger.Warn("unknown quest overfund mode; using raise", "quest", q.ID, "overfund", q.Overfund)
+			q.Overfund = OverfundRaise
+		}
+		if q.DurationSeconds < 0 {
+			q.DurationSeconds = 0
+		}
+		if _, ok := abilities[q.RewardAbility]; q.RewardAbility != "" && !ok {
+			logger.Warn("quest reward ignored: unknown ability", "quest", q.ID, "ability", q.RewardAbility)
+			q.RewardAbility = ""
+		}
 		quests[q.ID] = q
 	}
 
-	log.Printf("catalog loaded: %d abilities, %d quests\n", len(abilities), len(quests))
+	boards = make(map[string]Board, len(cf.Boards))
+	for _, b := range cf.Boards {
+		if b.MaxActive < 0 {
+			b.MaxActive = 0
+		}
+		boards[b.ID] = b
+	}
+
+	statusMu.Lock()
+	loadStatus = LoadStatus{Loaded: true, Abilities: len(abilities), Quests: len(quests), Boards: len(boards), LoadedAt: time.Now().Unix()}
+	statusMu.Unlock()
+
+	logger.Info("catalog loaded", "abilities", len(abilities), "quests", len(quests), "boards", len(boards))
+	return nil
+}
+
+// Status reports whether the catalog loaded and how many items it holds.
+func Status() LoadStatus {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	return loadStatus
+}
+
+// GetAbility returns the Ability with the given ID.
+func GetAbility(id string) (Ability, bool) {
+	a, ok := abilities[id]
+	return a, ok
 }
 
 // GetQuest returns the Quest with the given ID.
@@ -65,6 +209,30 @@ func GetQuest(id string) (Quest, bool) {
 	return q, ok
 }
 
+// GetBoard returns the request board with the given ID, or whose name
+// matches case-insensitively.
+func GetBoard(idOrName string) (Board, bool) {
+	if b, ok := boards[idOrName]; ok {
+		return b, true
+	}
+	for _, b := range boards {
+		if strings.EqualFold(b.Name, strings.TrimSpace(idOrName)) {
+			return b, true
+		}
+	}
+	return Board{}, false
+}
+
+// Boards returns every request board, ordered by name.
+func Boards() []Board {
+	out := make([]Board, 0, len(boards))
+	for _, b := range boards {
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
 // RegisterRoutes mounts the /api/catalog endpoints.
 func RegisterRoutes(r *chi.Mux) {
 	// GET /api/catalog
@@ -72,6 +240,7 @@ func RegisterRoutes(r *chi.Mux) {
 		type resp struct {
 			Abilities []Ability `json:"abilities"`
 			Quests    []Quest   `json:"quests"`
+			Boards    []Board   `json:"boards"`
 		}
 		abs := make([]Ability, 0, len(abilities))
 		for _, a := range abilities {
@@ -81,7 +250,6 @@ func RegisterRoutes(r *chi.Mux) {
 		for _, q := range quests {
 			qs = append(qs, q)
 		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(resp{Abilities: abs, Quests: qs})
+		api.OK(w, resp{Abilities: abs, Quests: qs, Boards: Boards()})
 	})
 }
@@ -0,0 +1,89 @@
+// Package events is an in-process bus for things that happen on stream:
+// abilities firing, call requests completing and donations arriving.
+// Packages that react to them (quest triggers, paid requests) subscribe
+// instead of being called directly by the package the event comes from.
+//
+// A donation's money can only be spent once. Before subscribers see a
+// donation it is offered to the claimers (paid requests, quest pots). The
+// first to take any of it lowers Cents to what is left; if nothing is left
+// it sets Claimed, and subscribers that would otherwise count the money
+// again (per-dollar quest triggers) skip it.
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dtorres47/stream-overlay/internal/logging"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
+)
+
+var logger = logging.For("events")
+
+// Event kinds. Catalog quest triggers name these in their "on" field.
+const (
+	AbilityFired     = "ability"           // Subject is the ability ID
+	RequestCompleted = "request_completed" // Subject is the request's board ID
+	Donation         = "donation"          // Donor, Message and Cents are set; Subject is the quest it funds, if any
+)
+
+// Event is one thing that happened.
+type Event struct {
+	Kind    string
+	Subject string
+	Donor   string
+	Message string
+	Cents   int64 // donations: what is left after claimers took their share
+	Claimed bool  // donations: a claimer has already spent all of the money
+}
+
+var mEvents = metrics.NewCounter("overlay_events_total", "Events published on the internal bus, by kind.", "kind")
+
+var (
+	subsMu   sync.Mutex
+	subs     []func(context.Context, Event)
+	claimers []func(context.Context, Event) int64
+)
+
+// Subscribe registers fn to receive every event. Handlers run in order on
+// the publishing goroutine, so they must not block; check e.Kind for the
+// events you want.
+func Subscribe(fn func(context.Context, Event)) {
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	subs = append(subs, fn)
+}
+
+// Claim registers fn to be offered every donation before subscribers see
+// it. fn returns how many cents it took, 0 for none; once one has taken
+// some, the rest aren't asked and subscribers see only what is left.
+// Claimers should take only donations that can't also be meant for another
+// claimer, so the order they registered in doesn't matter.
+func Claim(fn func(context.Context, Event) int64) {
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	claimers = append(claimers, fn)
+}
+
+// Publish delivers e to every subscriber, after offering a donation to the
+// claimers. Call it without holding locks a subscriber might need.
+func Publish(ctx context.Context, e Event) {
+	subsMu.Lock()
+	fns := append([]func(context.Context, Event){}, subs...)
+	claims := append([]func(context.Context, Event) int64{}, claimers...)
+	subsMu.Unlock()
+	mEvents.Inc(e.Kind)
+	if e.Kind == Donation {
+		for _, claim := range claims {
+			if took := min(claim(ctx, e), e.Cents); took > 0 {
+				e.Cents -= took
+				e.Claimed = e.Cents == 0
+				break
+			}
+		}
+	}
+	logger.DebugContext(ctx, "event", "kind", e.Kind, "subject", e.Subject, "cents", e.Cents, "claimed", e.Claimed)
+	for _, fn := range fns {
+		fn(ctx, e)
+	}
+}
@@ -0,0 +1,103 @@
+// Package health serves liveness and readiness probes.
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/dtorres47/stream-overlay/internal/buildinfo"
+	"github.com/dtorres47/stream-overlay/internal/catalog"
+	"github.com/dtorres47/stream-overlay/internal/history"
+	"github.com/dtorres47/stream-overlay/internal/requests"
+	"github.com/dtorres47/stream-overlay/internal/state"
+	"github.com/dtorres47/stream-overlay/internal/tts"
+	"github.com/dtorres47/stream-overlay/internal/ws"
+	"github.com/go-chi/chi/v5"
+)
+
+const service = "stream-overlay"
+
+type buildReport struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	BuiltAt string `json:"built_at,omitempty"`
+}
+
+type readyReport struct {
+	Status  string      `json:"status"`
+	Service string      `json:"service"`
+	Build   buildReport `json:"build"`
+	Uptime  int64       `json:"uptime_seconds"`
+
+	Catalog catalog.LoadStatus `json:"catalog"`
+	State   state.SaveStatus   `json:"state"`
+	Ledger  struct {
+		OK    bool   `json:"ok"`
+		Path  string `json:"path"`
+		Error string `json:"error,omitempty"`
+	} `json:"ledger"`
+	WS     ws.HubStatus `json:"ws"`
+	Queues struct {
+		TTSPending      int `json:"tts_pending"`
+		RequestsPending int `json:"requests_pending"`
+		RequestsActive  int `json:"requests_active"`
+	} `json:"queues"`
+}
+
+func build() buildReport {
+	return buildReport{Version: buildinfo.Version, Commit: buildinfo.Commit, BuiltAt: buildinfo.BuiltAt}
+}
+
+func uptime() int64 { return int64(time.Since(buildinfo.StartedAt).Seconds()) }
+
+// live answers as long as the process can serve HTTP.
+func live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"status":         "ok",
+		"service":        service,
+		"build":          build(),
+		"uptime_seconds": uptime(),
+	})
+}
+
+// ready checks every dependency the overlay needs to do useful work and
+// returns 503 if any of them is unhealthy.
+func ready(w http.ResponseWriter, r *http.Request) {
+	rep := readyReport{Service: service, Build: build(), Uptime: uptime()}
+
+	rep.Catalog = catalog.Status()
+	rep.State = state.Status()
+
+	rep.Ledger.Path = history.LedgerFile
+	if err := history.LedgerWritable(); err != nil {
+		rep.Ledger.Error = err.Error()
+	} else {
+		rep.Ledger.OK = true
+	}
+
+	rep.WS = ws.Status(time.Second)
+
+	rep.Queues.TTSPending = tts.PendingCount()
+	rep.Queues.RequestsPending = requests.PendingCount()
+	rep.Queues.RequestsActive = requests.ActiveCount()
+
+	code := http.StatusOK
+	rep.Status = "ok"
+	if !rep.Catalog.Loaded || rep.State.LastError != "" || !rep.Ledger.OK || !rep.WS.OK {
+		code = http.StatusServiceUnavailable
+		rep.Status = "unavailable"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(rep)
+}
+
+// RegisterRoutes mounts /api/health/live and /api/health/ready. The legacy
+// /api/health stays as an alias for liveness.
+func RegisterRoutes(r chi.Router) {
+	r.Get("/api/health", live)
+	r.Get("/api/health/live", live)
+	r.Get("/api/health/ready", ready)
+}
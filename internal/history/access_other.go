@@ -0,0 +1,19 @@
+//go:build !unix
+
+package history
+
+import "os"
+
+// canWrite opens an existing file for writing without creating or changing
+// it. Directories are assumed writable where there is no access(2).
+func canWrite(path string) error {
+	st, err := os.Stat(path)
+	if err != nil || st.IsDir() {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,11 @@
+//go:build unix
+
+package history
+
+import "syscall"
+
+// canWrite asks the kernel whether this process may write path.
+func canWrite(path string) error {
+	const wOK = 0x2 // W_OK
+	return syscall.Access(path, wOK)
+}
@@ -2,30 +2,88 @@ package history
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
 	"net/http"
 	"os"
+	"path/filepath"
 	"time"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/catalog"
+	"github.com/dtorres47/stream-overlay/internal/events"
+	"github.com/dtorres47/stream-overlay/internal/logging"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
 )
 
-// RecordDonation appends each incoming donation to web/data/donations.json
-func RecordDonation(w http.ResponseWriter, r *http.Request) {
-	// expected payload
-	var d struct {
-		Time    time.Time `json:"time"`
-		Donor   string    `json:"donor"`
-		Amount  float64   `json:"amount"`
-		Message string    `json:"message"`
+var logger = logging.For("history")
+
+var (
+	mDonations     = metrics.NewCounter("overlay_donations_total", "Donations recorded to the ledger.")
+	mDonationCents = metrics.NewCounter("overlay_donation_cents_total", "Sum of recorded donations in cents.")
+)
+
+// LedgerFile is the donation ledger path, relative to the working dir.
+const LedgerFile = "cmd/stream-overlay/web/data/donations.json"
+
+// LedgerWritable reports whether the ledger can be written: the file if it
+// exists, otherwise the directory it will be created in. It never creates
+// or modifies anything, so it is safe to call from probes.
+func LedgerWritable() error {
+	st, err := os.Stat(LedgerFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return canWrite(filepath.Dir(LedgerFile))
+	case err != nil:
+		return err
+	case !st.Mode().IsRegular():
+		return fmt.Errorf("%s is not a regular file", LedgerFile)
 	}
+	return canWrite(LedgerFile)
+}
+
+// RoleRecordDonations lets an operator record donations. A donation can
+// pay for a held request, so the endpoint is never open.
+const RoleRecordDonations = "record_donations"
+
+// Donation is one entry in the ledger, as posted to /api/donations.
+type Donation struct {
+	Time    time.Time `json:"time"`
+	Donor   string    `json:"donor"`
+	Amount  float64   `json:"amount"` // dollars
+	Message string    `json:"message"`
+	Quest   string    `json:"quest,omitempty"` // quest ID the donation funds, if any
+}
+
+// AmountCents is Amount rounded to whole cents.
+func (d Donation) AmountCents() int64 { return int64(math.Round(d.Amount * 100)) }
+
+// RecordDonation appends each incoming donation to web/data/donations.json.
+// It is mounted behind auth.Require(RoleRecordDonations).
+func RecordDonation(w http.ResponseWriter, r *http.Request) {
+	var d Donation
 	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
-		http.Error(w, "invalid payload", http.StatusBadRequest)
+		api.Error(w, http.StatusBadRequest, api.CodeBadRequest, "invalid JSON payload")
 		return
 	}
+	if d.Amount < 0 {
+		api.Invalid(w, "amount", "amount must not be negative")
+		return
+	}
+	if d.Quest != "" {
+		if q, ok := catalog.GetQuest(d.Quest); !ok || q.PriceCents <= 0 {
+			api.Invalid(w, "quest", "quest "+d.Quest+" doesn't exist or can't be funded")
+			return
+		}
+	}
 
-	// path relative to your working dir
-	file := "cmd/stream-overlay/web/data/donations.json"
+	file := LedgerFile
 	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
-		http.Error(w, "cannot open data file", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "cannot open donation ledger", "file", file, "err", err)
+		api.Error(w, http.StatusInternalServerError, api.CodeInternal, "cannot open data file")
 		return
 	}
 	defer f.Close()
@@ -42,5 +100,10 @@ func RecordDonation(w http.ResponseWriter, r *http.Request) {
 	f.Seek(0, 0)
 	json.NewEncoder(f).Encode(arr)
 
-	w.WriteHeader(http.StatusNoContent)
+	logger.InfoContext(r.Context(), "donation recorded", "donor", d.Donor, "amount", d.Amount, "message", d.Message)
+	mDonations.Inc()
+	mDonationCents.Add(float64(d.AmountCents()))
+	events.Publish(r.Context(), events.Event{Kind: events.Donation, Donor: d.Donor, Message: d.Message, Cents: d.AmountCents(), Subject: d.Quest})
+
+	api.Created(w, d)
 }
@@ -0,0 +1,256 @@
+// Package logging configures structured JSON logging via log/slog.
+//
+// Every subsystem gets its own logger (logging.For("ws")) whose level can be
+// tuned independently with LOG_LEVELS="ws=warn,tts=debug"; LOG_LEVEL sets the
+// fallback. Request IDs from chi's middleware.RequestID are attached to any
+// record logged with a request context, and attributes known to carry phone
+// numbers or donor messages are redacted before anything reaches stdout.
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Subsystems that have their own level knob.
+var Subsystems = []string{"http", "ws", "tts", "requests", "quests", "abilities", "events", "history", "state", "catalog", "auth", "main"}
+
+var (
+	root = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level:       slog.LevelDebug, // filtering happens per subsystem
+		ReplaceAttr: redact,
+	})
+
+	levelsMu sync.Mutex
+	levels   = map[string]*slog.LevelVar{}
+)
+
+func levelVar(name string) *slog.LevelVar {
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	lv, ok := levels[name]
+	if !ok {
+		lv = &slog.LevelVar{}
+		levels[name] = lv
+	}
+	return lv
+}
+
+// Setup reads LOG_LEVEL / LOG_LEVELS and routes the stdlib log package and
+// slog's default logger through the JSON handler.
+func Setup() {
+	def := parseLevel(os.Getenv("LOG_LEVEL"), slog.LevelInfo)
+	for _, name := range Subsystems {
+		levelVar(name).Set(def)
+	}
+	for _, pair := range strings.Split(os.Getenv("LOG_LEVELS"), ",") {
+		name, lvl, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		levelVar(strings.TrimSpace(name)).Set(parseLevel(lvl, def))
+	}
+	slog.SetDefault(For("main"))
+}
+
+// For returns the logger for a subsystem, tagged with "subsystem".
+func For(name string) *slog.Logger {
+	return slog.New(&levelHandler{
+		level: levelVar(name),
+		h:     root.WithAttrs([]slog.Attr{slog.String("subsystem", name)}),
+	})
+}
+
+// errUnknownSubsystem and errBadLevel are SetLevel's failures.
+var (
+	errUnknownSubsystem = errors.New("unknown subsystem")
+	errBadLevel         = errors.New("level must be debug, info, warn or error")
+)
+
+// SetLevel changes the level of a subsystem that has a logger.
+func SetLevel(name, level string) error {
+	levelsMu.Lock()
+	lv, ok := levels[name]
+	levelsMu.Unlock()
+	if !ok {
+		return errUnknownSubsystem
+	}
+	l, ok := lookupLevel(level)
+	if !ok {
+		return errBadLevel
+	}
+	lv.Set(l)
+	return nil
+}
+
+// Levels returns the current level of every known subsystem.
+func Levels() map[string]string {
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	out := make(map[string]string, len(levels))
+	for name, lv := range levels {
+		out[name] = strings.ToLower(lv.Level().String())
+	}
+	return out
+}
+
+func parseLevel(s string, def slog.Level) slog.Level {
+	if l, ok := lookupLevel(s); ok {
+		return l
+	}
+	return def
+}
+
+func lookupLevel(s string) (slog.Level, bool) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, false
+	}
+	return l, true
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Handler: per-subsystem level + request ID
+// ─────────────────────────────────────────────────────────────────────────────
+
+type levelHandler struct {
+	level slog.Leveler
+	h     slog.Handler
+}
+
+func (l *levelHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	return lvl >= l.level.Level()
+}
+
+func (l *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := middleware.GetReqID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return l.h.Handle(ctx, r)
+}
+
+func (l *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{level: l.level, h: l.h.WithAttrs(attrs)}
+}
+
+func (l *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{level: l.level, h: l.h.WithGroup(name)}
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Redaction
+// ─────────────────────────────────────────────────────────────────────────────
+
+// Attribute keys that carry viewer-supplied free text.
+var freeTextKeys = map[string]bool{"message": true, "donor_message": true, "text": true, "note": true}
+
+// Attribute keys that carry a phone number. Log numbers only under these;
+// other values are left alone so addresses, dates and IDs stay readable.
+var phoneKeys = map[string]bool{"phone": true, "phone_number": true, "e164": true}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && (a.Key == slog.MessageKey || a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+		return a
+	}
+	switch {
+	case phoneKeys[a.Key]:
+		a.Value = slog.StringValue(maskDigits(a.Value.String()))
+	case freeTextKeys[a.Key]:
+		if a.Value.String() != "" {
+			a.Value = slog.StringValue("[redacted]")
+		}
+	}
+	return a
+}
+
+func maskDigits(s string) string {
+	n := countDigits(s)
+	if n == 0 {
+		return s
+	}
+	if n <= 2 {
+		return "**"
+	}
+	var last2 []rune
+	for i := len(s) - 1; i >= 0 && len(last2) < 2; i-- {
+		if s[i] >= '0' && s[i] <= '9' {
+			last2 = append([]rune{rune(s[i])}, last2...)
+		}
+	}
+	return strings.Repeat("*", n-2) + string(last2)
+}
+
+func countDigits(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+	return n
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// HTTP
+// ─────────────────────────────────────────────────────────────────────────────
+
+var httpLog = For("http")
+
+// RequestLogger replaces middleware.Logger with one structured line per
+// request. Mount it after middleware.RequestID.
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		lvl := slog.LevelInfo
+		if status >= 500 {
+			lvl = slog.LevelError
+		}
+		httpLog.LogAttrs(r.Context(), lvl, "http request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote", r.RemoteAddr),
+		)
+	})
+}
+
+// RegisterRoutes mounts GET/POST /api/debug/log-level for runtime tuning.
+func RegisterRoutes(r chi.Router) {
+	r.Get("/api/debug/log-level", func(w http.ResponseWriter, r *http.Request) {
+		api.OK(w, Levels())
+	})
+	r.Post("/api/debug/log-level", func(w http.ResponseWriter, r *http.Request) {
+		subsystem, ok := api.QueryString(w, r, "subsystem")
+		if !ok {
+			return
+		}
+		switch err := SetLevel(subsystem, r.URL.Query().Get("level")); {
+		case errors.Is(err, errUnknownSubsystem):
+			api.NotFound(w, "subsystem")
+			return
+		case err != nil:
+			api.Invalid(w, "level", err.Error())
+			return
+		}
+		api.OK(w, Levels())
+	})
+}
@@ -0,0 +1,311 @@
+// Package metrics is a tiny in-process registry that renders counters,
+// gauges and histograms in the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// DefaultBuckets are latency buckets (seconds) suited to an HTTP API.
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+type collector interface {
+	write(w io.Writer)
+}
+
+var (
+	regMu      sync.Mutex
+	collectors []collector
+)
+
+func register(c collector) {
+	regMu.Lock()
+	defer regMu.Unlock()
+	collectors = append(collectors, c)
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Counters & gauges
+// ─────────────────────────────────────────────────────────────────────────────
+
+// Vec is a labelled family of float values, used for both counters and gauges.
+type Vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newVec(kind, name, help string, labels []string) *Vec {
+	v := &Vec{name: name, help: help, kind: kind, labels: labels, values: map[string]float64{}}
+	register(v)
+	return v
+}
+
+// NewCounter registers a monotonically increasing counter family.
+func NewCounter(name, help string, labels ...string) *Vec {
+	return newVec("counter", name, help, labels)
+}
+
+// NewGauge registers a gauge family whose values can go up and down.
+func NewGauge(name, help string, labels ...string) *Vec {
+	return newVec("gauge", name, help, labels)
+}
+
+// Add adds d to the series identified by labelValues.
+func (v *Vec) Add(d float64, labelValues ...string) {
+	k := v.key(labelValues)
+	v.mu.Lock()
+	v.values[k] += d
+	v.mu.Unlock()
+}
+
+// Inc adds 1 to the series identified by labelValues.
+func (v *Vec) Inc(labelValues ...string) { v.Add(1, labelValues...) }
+
+// Dec subtracts 1 from the series identified by labelValues (gauges only).
+func (v *Vec) Dec(labelValues ...string) { v.Add(-1, labelValues...) }
+
+// Set overwrites the series identified by labelValues (gauges only).
+func (v *Vec) Set(x float64, labelValues ...string) {
+	k := v.key(labelValues)
+	v.mu.Lock()
+	v.values[k] = x
+	v.mu.Unlock()
+}
+
+func (v *Vec) key(labelValues []string) string {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	return labelPairs(v.labels, labelValues)
+}
+
+func (v *Vec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	writeHeader(w, v.name, v.help, v.kind)
+	if len(v.labels) == 0 && len(v.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", v.name)
+		return
+	}
+	for _, k := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, k, formatFloat(v.values[k]))
+	}
+}
+
+// GaugeFunc is a gauge whose value is computed at scrape time.
+type GaugeFunc struct {
+	name string
+	help string
+	fn   func() float64
+}
+
+// NewGaugeFunc registers a gauge that calls fn on every scrape.
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, fn: fn}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.fn()))
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Histograms
+// ─────────────────────────────────────────────────────────────────────────────
+
+type histSeries struct {
+	counts []uint64 // one per bucket, non-cumulative
+	count  uint64
+	sum    float64
+}
+
+// Histogram is a labelled family of bucketed observations.
+type Histogram struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histSeries
+}
+
+// NewHistogram registers a histogram family. Buckets must be sorted ascending;
+// nil selects DefaultBuckets.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &Histogram{name: name, help: help, labels: labels, buckets: buckets, series: map[string]*histSeries{}}
+	register(h)
+	return h
+}
+
+// Observe records x in the series identified by labelValues.
+func (h *Histogram) Observe(x float64, labelValues ...string) {
+	if len(labelValues) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", h.name, len(h.labels), len(labelValues)))
+	}
+	k := strings.Join(labelValues, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[k]
+	if !ok {
+		s = &histSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[k] = s
+	}
+	for i, ub := range h.buckets {
+		if x <= ub {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += x
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	leNames := append(append([]string{}, h.labels...), "le")
+	for _, k := range keys {
+		s := h.series[k]
+		var lv []string
+		if len(h.labels) > 0 {
+			lv = strings.Split(k, "\xff")
+		}
+		leValues := append(append([]string{}, lv...), "")
+		var cum uint64
+		for i, ub := range h.buckets {
+			cum += s.counts[i]
+			leValues[len(lv)] = formatFloat(ub)
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelPairs(leNames, leValues), cum)
+		}
+		leValues[len(lv)] = "+Inf"
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelPairs(leNames, leValues), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labelPairs(h.labels, lv), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labelPairs(h.labels, lv), s.count)
+	}
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// HTTP instrumentation
+// ─────────────────────────────────────────────────────────────────────────────
+
+var httpDuration = NewHistogram("overlay_http_request_duration_seconds",
+	"HTTP request latency by chi route pattern.", nil, "method", "route", "code")
+
+// Middleware records request latency labelled by the matched chi route, so
+// /api/tts/approve?id=3 and ?id=4 land in the same series.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rc := chi.RouteContext(r.Context()); rc != nil {
+			if p := rc.RoutePattern(); p != "" {
+				route = p
+			}
+		}
+		code := ww.Status()
+		if code == 0 {
+			code = http.StatusOK
+		}
+		httpDuration.Observe(time.Since(start).Seconds(), r.Method, route, strconv.Itoa(code))
+	})
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Exposition
+// ─────────────────────────────────────────────────────────────────────────────
+
+// Handler serves every registered metric in Prometheus text format.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	WriteTo(w)
+}
+
+// WriteTo renders every registered metric to w.
+func WriteTo(w io.Writer) {
+	regMu.Lock()
+	cs := append([]collector{}, collectors...)
+	regMu.Unlock()
+	for _, c := range cs {
+		c.write(w)
+	}
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.ReplaceAll(help, "\n", " "))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+func labelPairs(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(n)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func escapeLabel(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
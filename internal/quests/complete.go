@@ -0,0 +1,242 @@
+package quests
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dtorres47/stream-overlay/internal/abilities"
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/catalog"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
+	"github.com/dtorres47/stream-overlay/internal/ws"
+)
+
+// A quest completes when its progress reaches its target. Overlays get
+// QUEST_COMPLETE with the catalog's celebration, the quest's reward ability
+// (if any) fires, and after a grace period the run moves to the archive
+// (as does a run that failed its deadline; see timers.go):
+// the quest starts its next run if repeats were paid for, and otherwise
+// leaves the active list. The archive is grouped by stream session; an
+// operator starts a new session at the top of each stream.
+
+// CompletedQuest is one finished run of a quest. Runs that missed their
+// deadline are kept too, with FailedUnix set.
+type CompletedQuest struct {
+	QuestState
+	ArchivedUnix int64 `json:"archived_unix"`
+}
+
+// Session is a stream and the quests completed during it.
+type Session struct {
+	ID          int              `json:"id"`
+	StartedUnix int64            `json:"started_unix"`
+	Quests      []CompletedQuest `json:"quests"`
+}
+
+// sessions is guarded by activeMu, oldest first; the last one is current.
+var sessions []*Session
+
+var mCompleted = metrics.NewCounter("overlay_quest_completed_total", "Quest runs completed, by quest.", "quest")
+
+// settleLocked records when qs completed, or clears it if more progress is
+// needed again (after a reset or a raised target). It reports whether qs
+// has just completed.
+func settleLocked(qs *QuestState) bool {
+	switch {
+	case qs.Progress < qs.Target:
+		qs.CompletedUnix = 0
+	case qs.CompletedUnix == 0:
+		currentSessionLocked()
+		qs.CompletedUnix = time.Now().Unix()
+		return true
+	}
+	return false
+}
+
+// celebrate announces a completed quest and fires its reward, cooldown or
+// not: the reward was earned and isn't dropped for being close to another
+// fire. Call it without activeMu held: the reward ability goes through the
+// event bus.
+func celebrate(ctx context.Context, qs QuestState) {
+	q, _ := catalog.GetQuest(qs.ID)
+	c := q.Celebration
+	c.TTS = strings.ReplaceAll(c.TTS, "{name}", qs.Name)
+	ws.Broadcast(ws.WSMsg{Type: "QUEST_COMPLETE", Data: map[string]any{"quest": qs, "celebration": c}})
+	mCompleted.Inc(qs.ID)
+	logger.InfoContext(ctx, "quest completed", "id", qs.ID, "target", qs.Target, "repeats_left", qs.Repeats)
+
+	if q.RewardAbility == "" {
+		return
+	}
+	if _, err := abilities.FireReward(ctx, q.RewardAbility); err != nil {
+		logger.WarnContext(ctx, "quest reward failed", "id", qs.ID, "ability", q.RewardAbility, "err", err)
+	}
+}
+
+// currentSessionLocked returns the current session, starting the first
+// one if there is none.
+func currentSessionLocked() *Session {
+	if len(sessions) == 0 {
+		sessions = append(sessions, &Session{ID: 1, StartedUnix: time.Now().Unix(), Quests: []CompletedQuest{}})
+	}
+	return sessions[len(sessions)-1]
+}
+
+// archiveLocked records qs's completed run in the session it completed in.
+func archiveLocked(qs *QuestState, now time.Time) {
+	s := currentSessionLocked()
+	for i := len(sessions) - 1; i > 0 && sessions[i].StartedUnix > finishedUnix(qs); i-- {
+		s = sessions[i-1]
+	}
+	run := CompletedQuest{QuestState: *qs, ArchivedUnix: now.Unix()}
+	run.FundedBy = append([]Contribution(nil), qs.FundedBy...)
+	s.Quests = append(s.Quests, run)
+}
+
+// archiveDue moves quests finished at or before cutoff to the archive,
+// restarting those with repeats left.
+func archiveDue(now time.Time, cutoff int64) {
+	activeMu.Lock()
+	var restarted []QuestState
+	var timed []map[string]any
+	var removed []string
+	for id, qs := range activeQuests {
+		if done := finishedUnix(qs); done == 0 || done > cutoff {
+			continue
+		}
+		archiveLocked(qs, now)
+		if qs.Repeats > 0 {
+			qs.Repeats--
+			qs.Progress = 0
+			qs.CompletedUnix = 0
+			// the next run lists only those who paid for it
+			qs.FundedBy = nil
+			if len(qs.RepeatFundedBy) > 0 {
+				qs.FundedBy = qs.RepeatFundedBy[0]
+				qs.RepeatFundedBy = qs.RepeatFundedBy[1:]
+			}
+			startTimerLocked(qs, now)
+			restarted = append(restarted, *qs)
+			if timedLocked(qs) {
+				timed = append(timed, timerMsg(qs, now))
+			}
+			continue
+		}
+		delete(activeQuests, id)
+		removed = append(removed, id)
+	}
+	activeMu.Unlock()
+
+	for _, qs := range restarted {
+		logger.Info("quest repeating", "id", qs.ID, "repeats_left", qs.Repeats)
+		ws.Broadcast(ws.WSMsg{Type: "QUEST_UPSERT", Data: qs})
+	}
+	for _, m := range timed {
+		ws.Broadcast(ws.WSMsg{Type: "QUEST_TIMER", Data: m})
+	}
+	for _, id := range removed {
+		logger.Info("quest archived", "id", id)
+		ws.Broadcast(ws.WSMsg{Type: "QUEST_REMOVE", Data: map[string]any{"id": id}})
+	}
+}
+
+// StartArchiver archives finished quests once they have been finished for
+// QUEST_ARCHIVE_GRACE (a Go duration, default 30s), checking every
+// interval.
+func StartArchiver(interval time.Duration) {
+	grace := 30 * time.Second
+	if v := os.Getenv("QUEST_ARCHIVE_GRACE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			logger.Warn("bad QUEST_ARCHIVE_GRACE; using default", "value", v, "default", grace)
+		} else {
+			grace = d
+		}
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for now := range t.C {
+			archiveDue(now, now.Add(-grace).Unix())
+		}
+	}()
+}
+
+// ListSessions returns a snapshot of the archive, oldest session first.
+func ListSessions() []Session {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	out := make([]Session, 0, len(sessions))
+	for _, s := range sessions {
+		c := *s
+		c.Quests = append([]CompletedQuest{}, s.Quests...)
+		out = append(out, c)
+	}
+	return out
+}
+
+// SetSessions replaces the archive (used by state.LoadState).
+func SetSessions(list []Session) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	sessions = make([]*Session, 0, len(list))
+	for _, s := range list {
+		s := s
+		if s.Quests == nil {
+			s.Quests = []CompletedQuest{}
+		}
+		sessions = append(sessions, &s)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// HTTP
+// ─────────────────────────────────────────────────────────────────────────────
+
+// handleCompleted lists completed quests by session, newest session first:
+// GET /api/quest/completed[?session=]
+func handleCompleted(w http.ResponseWriter, r *http.Request) {
+	want := 0
+	if r.URL.Query().Get("session") != "" {
+		id, ok := api.QueryInt(w, r, "session")
+		if !ok {
+			return
+		}
+		want = id
+	}
+	activeMu.Lock()
+	currentSessionLocked()
+	activeMu.Unlock()
+	all := ListSessions()
+	out := make([]Session, 0, len(all))
+	for i := len(all) - 1; i >= 0; i-- {
+		if want == 0 || all[i].ID == want {
+			out = append(out, all[i])
+		}
+	}
+	if want != 0 && len(out) == 0 {
+		api.NotFound(w, "session")
+		return
+	}
+	api.OK(w, out)
+}
+
+// handleNewSession starts a new stream session: POST /api/quest/session
+func handleNewSession(w http.ResponseWriter, r *http.Request) {
+	activeMu.Lock()
+	id := 1
+	if len(sessions) > 0 {
+		id = sessions[len(sessions)-1].ID + 1
+	}
+	s := &Session{ID: id, StartedUnix: time.Now().Unix(), Quests: []CompletedQuest{}}
+	sessions = append(sessions, s)
+	out := *s
+	activeMu.Unlock()
+	logger.InfoContext(r.Context(), "stream session started", "session", id)
+	api.Created(w, out)
+}
@@ -0,0 +1,160 @@
+package quests
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dtorres47/stream-overlay/internal/catalog"
+	"github.com/dtorres47/stream-overlay/internal/events"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
+	"github.com/dtorres47/stream-overlay/internal/ws"
+)
+
+// Quests with a price can be crowdfunded: a donation naming the quest adds
+// to its pot, and each time the pot reaches the price the quest is bought.
+// The first purchase makes it active; later ones follow the quest's
+// overfund mode, raising its target or paying for another run, though a
+// quest whose run has already completed or failed always gets another run.
+// Whatever is left over stays in the pot toward the next purchase.
+
+// Contribution is one donation toward a quest.
+type Contribution struct {
+	Donor string `json:"donor"`
+	Cents int64  `json:"cents"`
+	Unix  int64  `json:"unix"`
+}
+
+// Funding is the pot for a quest's next purchase.
+type Funding struct {
+	ID           string         `json:"id"`
+	Name         string         `json:"name"`
+	GoalCents    int64          `json:"goal_cents"`
+	RaisedCents  int64          `json:"raised_cents"`
+	Contributors []Contribution `json:"contributors"`
+}
+
+// funding is guarded by activeMu, so a purchase and the quest it changes
+// are updated together.
+var funding = map[string]*Funding{}
+
+var mFunded = metrics.NewCounter("overlay_quest_funded_total", "Crowdfunded quest purchases, by what they bought.", "result")
+
+// splitPot divides contributions, oldest first, into purchases of price
+// cents each, splitting a contribution that straddles two purchases. What
+// is left (less than price) is returned as the new pot.
+func splitPot(pot []Contribution, price int64) (purchases [][]Contribution, rest []Contribution) {
+	var cur []Contribution
+	var sum int64
+	for _, c := range pot {
+		for c.Cents > 0 {
+			take := min(c.Cents, price-sum)
+			cur = append(cur, Contribution{Donor: c.Donor, Cents: take, Unix: c.Unix})
+			c.Cents -= take
+			if sum += take; sum == price {
+				purchases = append(purchases, cur)
+				cur, sum = nil, 0
+			}
+		}
+	}
+	return purchases, cur
+}
+
+// fundFromDonation claims the whole of a donation naming a quest for that
+// quest's pot. It is registered with events.Claim.
+func fundFromDonation(ctx context.Context, e events.Event) int64 {
+	if e.Kind != events.Donation || e.Subject == "" || e.Cents <= 0 {
+		return 0
+	}
+	q, ok := catalog.GetQuest(e.Subject)
+	if !ok || q.PriceCents <= 0 {
+		logger.WarnContext(ctx, "donation names a quest that can't be funded", "quest", e.Subject, "donor", e.Donor)
+		return 0
+	}
+	donor := strings.TrimSpace(e.Donor)
+	if donor == "" {
+		donor = "Anonymous"
+	}
+
+	activeMu.Lock()
+	f, ok := funding[q.ID]
+	if !ok {
+		f = &Funding{ID: q.ID, Name: q.Name}
+		funding[q.ID] = f
+	}
+	// the price may have changed since earlier contributions; the pot is
+	// always split at today's price
+	f.GoalCents = q.PriceCents
+	f.RaisedCents += e.Cents
+	f.Contributors = append(f.Contributors, Contribution{Donor: donor, Cents: e.Cents, Unix: time.Now().Unix()})
+	purchases, rest := splitPot(f.Contributors, f.GoalCents)
+	f.Contributors = rest
+	f.RaisedCents -= int64(len(purchases)) * f.GoalCents
+
+	var bought []string
+	var qs *QuestState
+	for _, paid := range purchases {
+		var active bool
+		qs, active = activeQuests[q.ID]
+		switch {
+		case !active:
+			qs = upsertLocked(q)
+			bought = append(bought, "activated")
+		case q.Overfund == catalog.OverfundRepeat || finishedUnix(qs) != 0:
+			// a run that has completed or failed can't be raised; the
+			// purchase is its next run, started when this one is archived
+			qs.Repeats++
+			qs.RepeatFundedBy = append(qs.RepeatFundedBy, paid)
+			bought = append(bought, "repeat")
+			continue
+		default:
+			qs.Target += q.Target
+			settleLocked(qs)
+			bought = append(bought, "raised")
+		}
+		qs.FundedBy = append(qs.FundedBy, paid...)
+	}
+	pot := *f
+	if f.RaisedCents == 0 {
+		delete(funding, q.ID)
+	}
+	var state QuestState
+	if qs != nil {
+		state = *qs
+	}
+	activeMu.Unlock()
+
+	ws.Broadcast(ws.WSMsg{Type: "QUEST_FUNDING", Data: pot})
+	if qs != nil {
+		ws.Broadcast(ws.WSMsg{Type: "QUEST_UPSERT", Data: state})
+	}
+	for _, b := range bought {
+		mFunded.Inc(b)
+	}
+	logger.InfoContext(ctx, "quest funded", "id", q.ID, "donor", donor, "cents", e.Cents, "raised_cents", pot.RaisedCents, "goal_cents", pot.GoalCents, "bought", bought)
+	return e.Cents
+}
+
+// ListFunding returns a snapshot of every quest pot, ordered by quest ID.
+func ListFunding() []Funding {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	out := make([]Funding, 0, len(funding))
+	for _, f := range funding {
+		out = append(out, *f)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// SetFunding replaces the quest pots (used by state.LoadState).
+func SetFunding(list []Funding) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	funding = make(map[string]*Funding, len(list))
+	for _, f := range list {
+		f := f
+		funding[f.ID] = &f
+	}
+}
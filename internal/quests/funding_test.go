@@ -0,0 +1,85 @@
+package quests
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitPot(t *testing.T) {
+	c := func(donor string, cents int64) Contribution { return Contribution{Donor: donor, Cents: cents} }
+	tests := []struct {
+		name      string
+		pot       []Contribution
+		price     int64
+		purchases [][]Contribution
+		rest      []Contribution
+	}{
+		{
+			name:  "short of the price",
+			pot:   []Contribution{c("a", 300), c("b", 100)},
+			price: 500,
+			rest:  []Contribution{c("a", 300), c("b", 100)},
+		},
+		{
+			name:      "exact",
+			pot:       []Contribution{c("a", 300), c("b", 200)},
+			price:     500,
+			purchases: [][]Contribution{{c("a", 300), c("b", 200)}},
+		},
+		{
+			name:      "remainder stays with the last donor",
+			pot:       []Contribution{c("a", 300), c("b", 400)},
+			price:     500,
+			purchases: [][]Contribution{{c("a", 300), c("b", 200)}},
+			rest:      []Contribution{c("b", 200)},
+		},
+		{
+			name:      "one donation buys several",
+			pot:       []Contribution{c("a", 1200)},
+			price:     500,
+			purchases: [][]Contribution{{c("a", 500)}, {c("a", 500)}},
+			rest:      []Contribution{c("a", 200)},
+		},
+		{
+			name:  "donation straddles purchases",
+			pot:   []Contribution{c("a", 300), c("b", 900), c("c", 400)},
+			price: 500,
+			purchases: [][]Contribution{
+				{c("a", 300), c("b", 200)},
+				{c("b", 500)},
+				{c("b", 200), c("c", 300)},
+			},
+			rest: []Contribution{c("c", 100)},
+		},
+		{
+			// the price dropped from 500 to 200 after a paid 300 in; b's small
+			// donation must not end up negative
+			name:      "price lowered between donations",
+			pot:       []Contribution{c("a", 300), c("b", 10)},
+			price:     200,
+			purchases: [][]Contribution{{c("a", 200)}},
+			rest:      []Contribution{c("a", 100), c("b", 10)},
+		},
+		{
+			name:      "price lowered, pot now buys more than one",
+			pot:       []Contribution{c("a", 450), c("b", 50)},
+			price:     100,
+			purchases: [][]Contribution{{c("a", 100)}, {c("a", 100)}, {c("a", 100)}, {c("a", 100)}, {c("a", 50), c("b", 50)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			purchases, rest := splitPot(tt.pot, tt.price)
+			if !reflect.DeepEqual(purchases, tt.purchases) || !reflect.DeepEqual(rest, tt.rest) {
+				t.Errorf("splitPot = %v, rest %v; want %v, rest %v", purchases, rest, tt.purchases, tt.rest)
+			}
+			for _, p := range append(purchases, rest) {
+				for _, pc := range p {
+					if pc.Cents <= 0 {
+						t.Errorf("contribution %v is not positive", pc)
+					}
+				}
+			}
+		})
+	}
+}
@@ -2,15 +2,22 @@
 package quests
 
 import (
-	"encoding/json"
+	"context"
 	"net/http"
 	"sync"
+	"time"
 
+	"github.com/dtorres47/stream-overlay/internal/api"
 	"github.com/dtorres47/stream-overlay/internal/catalog"
+	"github.com/dtorres47/stream-overlay/internal/events"
+	"github.com/dtorres47/stream-overlay/internal/logging"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
 	"github.com/dtorres47/stream-overlay/internal/ws"
 	"github.com/go-chi/chi/v5"
 )
 
+var logger = logging.For("quests")
+
 // QuestState holds progress for an active quest.
 type QuestState struct {
 	ID         string `json:"id"`
@@ -19,6 +26,18 @@ type QuestState struct {
 	Progress   int    `json:"progress"`
 	IconURL    string `json:"icon_url"`
 	PriceCents int64  `json:"price_cents"`
+
+	// Set on crowdfunded quests; see funding.go.
+	Repeats        int              `json:"repeats,omitempty"`          // further runs paid for
+	FundedBy       []Contribution   `json:"funded_by,omitempty"`        // who paid for this run
+	RepeatFundedBy [][]Contribution `json:"repeat_funded_by,omitempty"` // who paid for each further run, next first
+
+	CompletedUnix int64 `json:"completed_unix,omitempty"` // set while a completed run waits to be archived
+
+	// Set on timed quests; see timers.go.
+	DeadlineUnix    int64 `json:"deadline_unix,omitempty"`            // while the timer runs
+	PausedRemaining int64 `json:"paused_remaining_seconds,omitempty"` // while timers are paused
+	FailedUnix      int64 `json:"failed_unix,omitempty"`              // the run missed its deadline
 }
 
 var (
@@ -26,11 +45,10 @@ var (
 	activeMu     sync.Mutex
 )
 
-// upsertQuestState creates or updates an active quest and broadcasts it.
-func upsertQuestState(q catalog.Quest) *QuestState {
-	activeMu.Lock()
-	defer activeMu.Unlock()
+var mTriggered = metrics.NewCounter("overlay_quest_triggered_total", "Quest progress applied automatically by catalog triggers.", "quest", "event")
 
+// upsertLocked creates or updates an active quest and broadcasts it.
+func upsertLocked(q catalog.Quest) *QuestState {
 	qs, ok := activeQuests[q.ID]
 	if !ok {
 		qs = &QuestState{
@@ -42,6 +60,7 @@ func upsertQuestState(q catalog.Quest) *QuestState {
 			PriceCents: q.PriceCents,
 		}
 		activeQuests[q.ID] = qs
+		startTimerLocked(qs, time.Now())
 	} else {
 		// update any changed fields
 		qs.Name = q.Name
@@ -55,10 +74,67 @@ func upsertQuestState(q catalog.Quest) *QuestState {
 		}
 	}
 
+	logger.Info("quest upserted", "id", qs.ID, "progress", qs.Progress, "target", qs.Target)
 	ws.Broadcast(ws.WSMsg{Type: "QUEST_UPSERT", Data: qs})
+	if timedLocked(qs) {
+		ws.Broadcast(ws.WSMsg{Type: "QUEST_TIMER", Data: timerMsg(qs, time.Now())})
+	}
 	return qs
 }
 
+// advanceLocked adds n to a quest's progress and reports whether that
+// completed it.
+func advanceLocked(qs *QuestState, n int) bool {
+	qs.Progress = min(qs.Progress+n, qs.Target)
+	return settleLocked(qs)
+}
+
+// stepFor is how much progress e adds through t, or 0 if it doesn't match.
+// A donation already spent on a request or a quest pot adds nothing.
+func stepFor(t catalog.Trigger, e events.Event) int {
+	if t.On != e.Kind || (t.ID != "" && t.ID != e.Subject) || e.Claimed {
+		return 0
+	}
+	if t.PerDollar {
+		return int(e.Cents / 100)
+	}
+	return t.Step
+}
+
+// applyTriggers advances active quests whose catalog triggers match e.
+// It is subscribed to the event bus.
+func applyTriggers(ctx context.Context, e events.Event) {
+	activeMu.Lock()
+	var changed, completed []QuestState
+	for _, qs := range activeQuests {
+		q, ok := catalog.GetQuest(qs.ID)
+		if !ok || qs.Progress >= qs.Target || qs.FailedUnix != 0 {
+			continue
+		}
+		n := 0
+		for _, t := range q.Triggers {
+			n += stepFor(t, e)
+		}
+		if n <= 0 {
+			continue
+		}
+		if advanceLocked(qs, n) {
+			completed = append(completed, *qs)
+		}
+		changed = append(changed, *qs)
+	}
+	activeMu.Unlock()
+
+	for _, qs := range changed {
+		mTriggered.Inc(qs.ID, e.Kind)
+		logger.InfoContext(ctx, "quest progress from event", "id", qs.ID, "event", e.Kind, "subject", e.Subject, "progress", qs.Progress, "target", qs.Target)
+		ws.Broadcast(ws.WSMsg{Type: "QUEST_UPSERT", Data: qs})
+	}
+	for _, qs := range completed {
+		celebrate(ctx, qs)
+	}
+}
+
 // listActiveQuests returns a snapshot of all active quests.
 func listActiveQuests() []QuestState {
 	activeMu.Lock()
@@ -73,76 +149,141 @@ func listActiveQuests() []QuestState {
 
 // RegisterRoutes mounts the /api/quest/* endpoints on the given router.
 func RegisterRoutes(r chi.Router) {
+	events.Subscribe(applyTriggers)
+	events.Claim(fundFromDonation)
+
+	r.Get("/api/quest/funding", func(w http.ResponseWriter, r *http.Request) {
+		api.OK(w, ListFunding())
+	})
+	r.Get("/api/quest/completed", handleCompleted)
+	r.Post("/api/quest/session", handleNewSession)
+	r.Get("/api/quest/timers", handleGetTimers)
+	r.Post("/api/quest/timers", handlePauseTimers)
+
 	// Add or upsert a quest by ID
 	r.Get("/api/quest/add", func(w http.ResponseWriter, r *http.Request) {
-		id := r.URL.Query().Get("id")
+		id, ok := api.QueryString(w, r, "id")
+		if !ok {
+			return
+		}
 		q, ok := catalog.GetQuest(id)
 		if !ok {
-			http.Error(w, "unknown quest id", http.StatusNotFound)
+			api.NotFound(w, "quest id")
 			return
 		}
-		upsertQuestState(q)
-		w.Write([]byte("Quest upserted"))
+		activeMu.Lock()
+		qs := upsertLocked(q)
+		completed := settleLocked(qs)
+		out := *qs
+		activeMu.Unlock()
+		if completed {
+			celebrate(r.Context(), out)
+		}
+		api.OK(w, out)
 	})
 
 	// List all active quests
 	r.Get("/api/quest/active", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(listActiveQuests())
+		api.OK(w, listActiveQuests())
 	})
 
 	// Increment progress on an active quest
 	r.Post("/api/quest/inc", func(w http.ResponseWriter, r *http.Request) {
-		id := r.URL.Query().Get("id")
+		id, ok := api.QueryString(w, r, "id")
+		if !ok {
+			return
+		}
 		activeMu.Lock()
 		qs, ok := activeQuests[id]
-		if ok && qs.Progress < qs.Target {
-			qs.Progress++
+		var out QuestState
+		done, failed, completed := false, false, false
+		if ok {
+			switch {
+			case qs.FailedUnix != 0:
+				failed = true
+			case qs.Progress < qs.Target:
+				completed = advanceLocked(qs, 1)
+			default:
+				done = true
+			}
+			out = *qs
 		}
 		activeMu.Unlock()
 
-		if !ok {
-			http.Error(w, "unknown active quest id", http.StatusNotFound)
+		switch {
+		case !ok:
+			api.NotFound(w, "active quest id")
+			return
+		case failed:
+			api.Conflict(w, "quest "+id+" has failed; reset it to try again")
+			return
+		case done:
+			api.Conflict(w, "quest "+id+" is already complete")
 			return
 		}
-		ws.Broadcast(ws.WSMsg{Type: "QUEST_UPSERT", Data: qs})
-		w.Write([]byte("ok"))
+		logger.DebugContext(r.Context(), "quest progress", "id", id, "progress", out.Progress, "target", out.Target)
+		ws.Broadcast(ws.WSMsg{Type: "QUEST_UPSERT", Data: out})
+		if completed {
+			celebrate(r.Context(), out)
+		}
+		api.OK(w, out)
 	})
 
-	// Reset progress on an active quest
+	// Reset progress on an active quest, restarting its timer
 	r.Post("/api/quest/reset", func(w http.ResponseWriter, r *http.Request) {
-		id := r.URL.Query().Get("id")
+		id, ok := api.QueryString(w, r, "id")
+		if !ok {
+			return
+		}
 		activeMu.Lock()
 		qs, ok := activeQuests[id]
+		var out QuestState
+		var timer map[string]any
 		if ok {
+			now := time.Now()
 			qs.Progress = 0
+			settleLocked(qs)
+			startTimerLocked(qs, now)
+			out = *qs
+			if timedLocked(qs) {
+				timer = timerMsg(qs, now)
+			}
 		}
 		activeMu.Unlock()
 
 		if !ok {
-			http.Error(w, "unknown active quest id", http.StatusNotFound)
+			api.NotFound(w, "active quest id")
 			return
 		}
-		ws.Broadcast(ws.WSMsg{Type: "QUEST_UPSERT", Data: qs})
-		w.Write([]byte("ok"))
+		ws.Broadcast(ws.WSMsg{Type: "QUEST_UPSERT", Data: out})
+		if timer != nil {
+			ws.Broadcast(ws.WSMsg{Type: "QUEST_TIMER", Data: timer})
+		}
+		api.OK(w, out)
 	})
 
-	// Remove an active quest
+	// Remove an active quest; a finished run not yet archived is archived
 	r.Post("/api/quest/remove", func(w http.ResponseWriter, r *http.Request) {
-		id := r.URL.Query().Get("id")
+		id, ok := api.QueryString(w, r, "id")
+		if !ok {
+			return
+		}
 		activeMu.Lock()
-		_, ok := activeQuests[id]
+		qs, ok := activeQuests[id]
 		if ok {
+			if finishedUnix(qs) != 0 {
+				archiveLocked(qs, time.Now())
+			}
 			delete(activeQuests, id)
 		}
 		activeMu.Unlock()
 
 		if !ok {
-			http.Error(w, "unknown active quest id", http.StatusNotFound)
+			api.NotFound(w, "active quest id")
 			return
 		}
 		ws.Broadcast(ws.WSMsg{Type: "QUEST_REMOVE", Data: map[string]any{"id": id}})
-		w.Write([]byte("ok"))
+		api.OK(w, map[string]any{"id": id})
 	})
 }
 
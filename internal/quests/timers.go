@@ -0,0 +1,203 @@
+package quests
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/catalog"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
+	"github.com/dtorres47/stream-overlay/internal/ws"
+)
+
+// A quest with a duration in the catalog must be completed before its
+// deadline. The clock starts with each run and stops when the run
+// completes; a run still short of its target at the deadline fails, and is
+// archived like a completed one. While the stream is on break the operator
+// pauses every timer: each running quest keeps its remaining time and gets
+// a fresh deadline on resume.
+//
+// Overlays get QUEST_TIMER whenever a deadline changes, and again every
+// timerSync so late joiners and drifting clocks catch up. It carries the
+// remaining seconds as well as the deadline, so an overlay can count down
+// without trusting its own clock.
+
+// TimerState is whether quest timers are paused for a break.
+type TimerState struct {
+	Paused     bool  `json:"paused"`
+	PausedUnix int64 `json:"paused_unix,omitempty"`
+}
+
+// timerSync is how often running timers are rebroadcast.
+const timerSync = 10 * time.Second
+
+// timers is guarded by activeMu.
+var timers TimerState
+
+var mFailed = metrics.NewCounter("overlay_quest_failed_total", "Quest runs that missed their deadline, by quest.", "quest")
+
+// finishedUnix is when qs's run completed or failed, or 0 while it is
+// still going.
+func finishedUnix(qs *QuestState) int64 {
+	return max(qs.CompletedUnix, qs.FailedUnix)
+}
+
+// startTimerLocked starts the clock on a new run of qs, if its quest has a
+// duration.
+func startTimerLocked(qs *QuestState, now time.Time) {
+	qs.DeadlineUnix, qs.PausedRemaining, qs.FailedUnix = 0, 0, 0
+	q, ok := catalog.GetQuest(qs.ID)
+	if !ok || q.DurationSeconds <= 0 {
+		return
+	}
+	if timers.Paused {
+		qs.PausedRemaining = int64(q.DurationSeconds)
+		return
+	}
+	qs.DeadlineUnix = now.Unix() + int64(q.DurationSeconds)
+}
+
+// timedLocked reports whether qs has a clock that is still relevant.
+func timedLocked(qs *QuestState) bool {
+	return (qs.DeadlineUnix != 0 || qs.PausedRemaining != 0) && finishedUnix(qs) == 0
+}
+
+// timerMsg is the QUEST_TIMER payload for qs.
+func timerMsg(qs *QuestState, now time.Time) map[string]any {
+	remaining := qs.PausedRemaining
+	if qs.DeadlineUnix != 0 {
+		remaining = max(0, qs.DeadlineUnix-now.Unix())
+	}
+	return map[string]any{
+		"id":                qs.ID,
+		"deadline_unix":     qs.DeadlineUnix,
+		"remaining_seconds": remaining,
+		"paused":            qs.DeadlineUnix == 0,
+	}
+}
+
+// broadcastTimers sends QUEST_TIMER for every timed quest still running.
+func broadcastTimers(now time.Time) {
+	activeMu.Lock()
+	var msgs []map[string]any
+	for _, qs := range activeQuests {
+		if timedLocked(qs) {
+			msgs = append(msgs, timerMsg(qs, now))
+		}
+	}
+	activeMu.Unlock()
+	for _, m := range msgs {
+		ws.Broadcast(ws.WSMsg{Type: "QUEST_TIMER", Data: m})
+	}
+}
+
+// expireDeadlines fails running quests whose deadline has passed.
+func expireDeadlines(now time.Time) {
+	activeMu.Lock()
+	var failed []QuestState
+	for _, qs := range activeQuests {
+		if qs.DeadlineUnix == 0 || finishedUnix(qs) != 0 || now.Unix() < qs.DeadlineUnix {
+			continue
+		}
+		currentSessionLocked()
+		qs.FailedUnix = now.Unix()
+		failed = append(failed, *qs)
+	}
+	activeMu.Unlock()
+
+	for _, qs := range failed {
+		mFailed.Inc(qs.ID)
+		logger.Info("quest failed: deadline passed", "id", qs.ID, "progress", qs.Progress, "target", qs.Target)
+		ws.Broadcast(ws.WSMsg{Type: "QUEST_FAILED", Data: map[string]any{"quest": qs}})
+	}
+}
+
+// StartTimers checks quest deadlines every interval and rebroadcasts
+// running timers every timerSync.
+func StartTimers(interval time.Duration) {
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		var synced time.Time
+		for now := range t.C {
+			expireDeadlines(now)
+			if now.Sub(synced) >= timerSync {
+				broadcastTimers(now)
+				synced = now
+			}
+		}
+	}()
+}
+
+// setPaused pauses or resumes every running timer, reporting whether the
+// state changed.
+func setPaused(paused bool, now time.Time) bool {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	if timers.Paused == paused {
+		return false
+	}
+	for _, qs := range activeQuests {
+		if !timedLocked(qs) {
+			continue
+		}
+		if paused {
+			qs.PausedRemaining = max(1, qs.DeadlineUnix-now.Unix())
+			qs.DeadlineUnix = 0
+		} else {
+			qs.DeadlineUnix = now.Unix() + qs.PausedRemaining
+			qs.PausedRemaining = 0
+		}
+	}
+	timers = TimerState{Paused: paused}
+	if paused {
+		timers.PausedUnix = now.Unix()
+	}
+	return true
+}
+
+// GetTimers returns whether quest timers are paused.
+func GetTimers() TimerState {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	return timers
+}
+
+// SetTimers replaces the pause state (used by state.LoadState).
+func SetTimers(t TimerState) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	timers = t
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// HTTP
+// ─────────────────────────────────────────────────────────────────────────────
+
+func handleGetTimers(w http.ResponseWriter, r *http.Request) {
+	api.OK(w, GetTimers())
+}
+
+// handlePauseTimers pauses quest timers for a break, or resumes them:
+// POST /api/quest/timers?paused=true|false
+func handlePauseTimers(w http.ResponseWriter, r *http.Request) {
+	var paused bool
+	switch r.URL.Query().Get("paused") {
+	case "true":
+		paused = true
+	case "false":
+	default:
+		api.Invalid(w, "paused", "paused must be true or false")
+		return
+	}
+	now := time.Now()
+	if setPaused(paused, now) {
+		msg := "quest timers resumed"
+		if paused {
+			msg = "quest timers paused"
+		}
+		logger.InfoContext(r.Context(), msg)
+		broadcastTimers(now)
+	}
+	api.OK(w, GetTimers())
+}
@@ -0,0 +1,94 @@
+package requests
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/catalog"
+)
+
+// Requests belong to a board from the catalog (RequestItem.Board is its
+// ID). Boards share the request store; each board's queue is the pending
+// requests carrying its ID, in arrival order.
+
+// capacityError is an approval refused because the board is full.
+type capacityError struct {
+	Board string
+	Max   int
+}
+
+func (e *capacityError) Error() string {
+	return fmt.Sprintf("board %s already has %d active request(s); finish one first", e.Board, e.Max)
+}
+
+// checkCapacityLocked refuses another active request on a board that has
+// reached its MaxActive.
+func checkCapacityLocked(boardID string) error {
+	b, ok := catalog.GetBoard(boardID)
+	if !ok || b.MaxActive <= 0 {
+		return nil
+	}
+	n := 0
+	for _, it := range reqActive {
+		if it.Board == b.ID {
+			n++
+		}
+	}
+	if n >= b.MaxActive {
+		return &capacityError{Board: b.Name, Max: b.MaxActive}
+	}
+	return nil
+}
+
+// boardName is a board's display name, falling back to the stored value
+// for requests whose board has left the catalog.
+func boardName(id string) string {
+	if b, ok := catalog.GetBoard(id); ok {
+		return b.Name
+	}
+	return id
+}
+
+// queryBoard reads the optional ?board= filter as a board ID. An unknown
+// board gets a 404 and ok=false.
+func queryBoard(w http.ResponseWriter, r *http.Request) (id string, ok bool) {
+	raw := strings.TrimSpace(r.URL.Query().Get("board"))
+	if raw == "" {
+		return "", true
+	}
+	b, found := catalog.GetBoard(raw)
+	if !found {
+		api.NotFound(w, "board")
+		return "", false
+	}
+	return b.ID, true
+}
+
+// BoardSummary is a board with how many requests it holds.
+type BoardSummary struct {
+	catalog.Board
+	Pending int `json:"pending"`
+	Active  int `json:"active"`
+}
+
+// handleBoards lists request boards with their queue sizes:
+// GET /api/request/boards
+func handleBoards(w http.ResponseWriter, r *http.Request) {
+	reqMu.Lock()
+	pending := map[string]int{}
+	active := map[string]int{}
+	for _, it := range reqQueue {
+		pending[it.Board]++
+	}
+	for _, it := range reqActive {
+		active[it.Board]++
+	}
+	reqMu.Unlock()
+	out := []BoardSummary{}
+	for _, b := range catalog.Boards() {
+		out = append(out, BoardSummary{Board: b, Pending: pending[b.ID], Active: active[b.ID]})
+	}
+	api.OK(w, out)
+}
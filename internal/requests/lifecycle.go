@@ -0,0 +1,435 @@
+package requests
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/auth"
+	"github.com/dtorres47/stream-overlay/internal/catalog"
+	"github.com/dtorres47/stream-overlay/internal/events"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
+	"github.com/dtorres47/stream-overlay/internal/ws"
+)
+
+// Request states. A request moves pending → approved → in_call and ends as
+// completed, failed or no_answer; it can also be rejected or expire before
+// the call starts, or be merged into an earlier duplicate on submit.
+// Requests on paid boards start out awaiting_payment.
+const (
+	StatusAwaitingPayment = "awaiting_payment"
+	StatusPending         = "pending"
+	StatusApproved        = "approved"
+	StatusInCall          = "in_call"
+	StatusCompleted       = "completed"
+	StatusFailed          = "failed"
+	StatusNoAnswer        = "no_answer"
+	StatusRejected        = "rejected"
+	StatusExpired         = "expired"
+	StatusMerged          = "merged"
+)
+
+// transitions lists the legal next states for each state; anything missing
+// is terminal.
+var transitions = map[string][]string{
+	StatusAwaitingPayment: {StatusPending, StatusRejected, StatusExpired, StatusMerged},
+	StatusPending:         {StatusApproved, StatusRejected, StatusExpired, StatusMerged},
+	StatusApproved:        {StatusInCall, StatusRejected, StatusExpired},
+	StatusInCall:          {StatusCompleted, StatusFailed, StatusNoAnswer},
+}
+
+// Transition records one state change on a request.
+type Transition struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Unix     int64  `json:"unix"`
+	Operator string `json:"operator"`
+	Note     string `json:"note,omitempty"`
+}
+
+var mTransitions = metrics.NewCounter("overlay_request_transitions_total", "Call-request state changes by target state.", "to")
+
+// Terminal reports whether a request in status can no longer change.
+func Terminal(status string) bool { _, ok := transitions[status]; return !ok }
+
+func canMove(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+var errNotFound = errors.New("unknown request")
+
+// transitionError is an illegal move for the item's current state.
+type transitionError struct {
+	ID       int
+	From, To string
+}
+
+func (e *transitionError) Error() string {
+	return fmt.Sprintf("request %d is %s; cannot move to %s", e.ID, e.From, e.To)
+}
+
+// transition moves request id to state to, recording who did it. The item
+// is filed under the queue, active set or call log to match its new state.
+func transition(id int, to, operator, note string) (RequestItem, error) {
+	reqMu.Lock()
+	defer reqMu.Unlock()
+	it, ok := findLocked(id)
+	if !ok {
+		return RequestItem{}, errNotFound
+	}
+	if !canMove(it.Status, to) {
+		return RequestItem{}, &transitionError{ID: id, From: it.Status, To: to}
+	}
+	if to == StatusApproved {
+		if err := checkCapacityLocked(it.Board); err != nil {
+			return RequestItem{}, err
+		}
+	}
+	transitionLocked(it, to, operator, note)
+	return it.public(), nil
+}
+
+// transitionLocked applies a move already known to be legal.
+func transitionLocked(it *RequestItem, to, operator, note string) {
+	now := time.Now().Unix()
+	it.History = append(it.History, Transition{From: it.Status, To: to, Unix: now, Operator: operator, Note: note})
+	it.Status = to
+	it.UpdatedUnix = now
+	if Terminal(to) {
+		it.OutcomeNote = note
+	}
+	fileLocked(it)
+	mTransitions.Inc(to)
+}
+
+// enteredUnix is when it last moved into status, or 0 if its history has no
+// such move. Requests submitted straight into pending have no transition
+// for it, so their creation time stands in.
+func enteredUnix(it *RequestItem, status string) int64 {
+	for i := len(it.History) - 1; i >= 0; i-- {
+		if it.History[i].To == status {
+			return it.History[i].Unix
+		}
+	}
+	if status == StatusPending {
+		return it.CreatedUnix
+	}
+	return 0
+}
+
+func findLocked(id int) (*RequestItem, bool) {
+	for _, it := range reqHeld {
+		if it.ID == id {
+			return it, true
+		}
+	}
+	for _, it := range reqQueue {
+		if it.ID == id {
+			return it, true
+		}
+	}
+	if it, ok := reqActive[id]; ok {
+		return it, true
+	}
+	for _, it := range reqLog {
+		if it.ID == id {
+			return it, true
+		}
+	}
+	return nil, false
+}
+
+// fileLocked puts it in the one collection matching its status.
+func fileLocked(it *RequestItem) {
+	reqHeld = removeItem(reqHeld, it)
+	reqQueue = removeItem(reqQueue, it)
+	delete(reqActive, it.ID)
+	switch {
+	case it.Status == StatusAwaitingPayment:
+		reqHeld = append(reqHeld, it)
+	case it.Status == StatusPending:
+		reqQueue = append(reqQueue, it)
+	case Terminal(it.Status):
+		reqLog = append(reqLog, it)
+	default:
+		reqActive[it.ID] = it
+	}
+}
+
+func removeItem(list []*RequestItem, it *RequestItem) []*RequestItem {
+	for i, q := range list {
+		if q == it {
+			return append(list[:i], list[i+1:]...)
+		}
+	}
+	return list
+}
+
+// operatorFrom names who is acting for audit fields. Only an authenticated
+// operator counts; anything else is recorded as "panel".
+func operatorFrom(r *http.Request) string {
+	if op, ok := auth.FromContext(r.Context()); ok {
+		return op.Name
+	}
+	if op, ok := auth.Identify(r); ok {
+		return op.Name
+	}
+	return "panel"
+}
+
+// writeTransitionErr maps transition errors onto 404/409.
+func writeTransitionErr(w http.ResponseWriter, err error) {
+	var te *transitionError
+	var ce *capacityError
+	switch {
+	case errors.Is(err, errNotFound):
+		api.NotFound(w, "request")
+	case errors.As(err, &te):
+		api.Conflict(w, te.Error())
+	case errors.As(err, &ce):
+		api.Conflict(w, ce.Error())
+	default:
+		api.Error(w, http.StatusInternalServerError, api.CodeInternal, err.Error())
+	}
+}
+
+// announce tells overlays about a state change: approved requests appear,
+// calls in progress are highlighted, and finished ones disappear.
+func announce(it RequestItem, from string) {
+	switch {
+	case it.Status == StatusApproved:
+		ws.Broadcast(ws.WSMsg{Type: "REQUEST_ADD", Data: OverlayView(it)})
+	case it.Status == StatusInCall:
+		ws.Broadcast(ws.WSMsg{Type: "REQUEST_UPDATE", Data: map[string]any{"id": it.ID, "status": it.Status}})
+	case Terminal(it.Status) && from != StatusPending && from != StatusAwaitingPayment:
+		ws.Broadcast(ws.WSMsg{Type: "REQUEST_REMOVE", Data: map[string]any{"id": it.ID}})
+	}
+}
+
+// OverlayView is what overlays are told about a request, including its
+// board's name and styling so each board can have its own section.
+func OverlayView(it RequestItem) map[string]any {
+	b, _ := catalog.GetBoard(it.Board)
+	return map[string]any{
+		"id":           it.ID,
+		"board":        it.Board,
+		"board_name":   boardName(it.Board),
+		"board_style":  b.Style,
+		"masked_phone": it.MaskedPhone,
+		"phone_region": it.PhoneRegion,
+		"note":         it.Note,
+		"status":       it.Status,
+		"duplicates":   it.Duplicates,
+	}
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Expiry
+// ─────────────────────────────────────────────────────────────────────────────
+
+// StartExpiry expires requests that sat pending or approved longer than
+// REQUEST_TTL (default 2h), and unpaid holds older than REQUEST_PAYMENT_TTL
+// (default 30m), checking every interval. Both are Go durations; "0"
+// disables.
+func StartExpiry(interval time.Duration) {
+	ttl := envDuration("REQUEST_TTL", 2*time.Hour)
+	payTTL := envDuration("REQUEST_PAYMENT_TTL", 30*time.Minute)
+	if ttl <= 0 && payTTL <= 0 {
+		logger.Info("request expiry disabled")
+		return
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for now := range t.C {
+			if ttl > 0 {
+				expireStale(now.Add(-ttl).Unix())
+			}
+			if payTTL > 0 {
+				expireUnpaid(now.Add(-payTTL).Unix())
+			}
+		}
+	}()
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		logger.Warn("bad "+name+"; using default", "value", v, "default", def)
+		return def
+	}
+	return d
+}
+
+func expireUnpaid(cutoff int64) {
+	reqMu.Lock()
+	var stale []int
+	for _, it := range reqHeld {
+		if it.CreatedUnix < cutoff {
+			stale = append(stale, it.ID)
+		}
+	}
+	reqMu.Unlock()
+	for _, id := range stale {
+		if _, err := transition(id, StatusExpired, "system", "not paid in time"); err != nil {
+			continue // paid since we looked
+		}
+		mPayments.Inc("expired")
+		logger.Info("unpaid request expired", "id", id)
+	}
+}
+
+func expireStale(cutoff int64) {
+	reqMu.Lock()
+	var stale []int
+	// measured from when each request reached its current state, so time
+	// spent awaiting payment or in the queue doesn't count against approval
+	for _, it := range reqQueue {
+		if enteredUnix(it, StatusPending) < cutoff {
+			stale = append(stale, it.ID)
+		}
+	}
+	for _, it := range reqActive {
+		if it.Status != StatusApproved {
+			continue
+		}
+		since := enteredUnix(it, StatusApproved)
+		if since == 0 {
+			since = it.CreatedUnix // saved before history was kept
+		}
+		if since < cutoff {
+			stale = append(stale, it.ID)
+		}
+	}
+	reqMu.Unlock()
+	for _, id := range stale {
+		it, err := transition(id, StatusExpired, "system", "not handled in time")
+		if err != nil {
+			continue // moved on since we looked
+		}
+		announce(it, it.History[len(it.History)-1].From)
+		logger.Info("request expired", "id", id)
+	}
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// HTTP
+// ─────────────────────────────────────────────────────────────────────────────
+
+// moveHandler serves the fixed-target endpoints (approve, reject, start).
+func moveHandler(to string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := api.QueryInt(w, r, "id")
+		if !ok {
+			return
+		}
+		doTransition(w, r, id, to)
+	}
+}
+
+func doTransition(w http.ResponseWriter, r *http.Request, id int, to string) {
+	op := operatorFrom(r)
+	it, err := transition(id, to, op, strings.TrimSpace(r.URL.Query().Get("note")))
+	if err != nil {
+		writeTransitionErr(w, err)
+		return
+	}
+	from := it.History[len(it.History)-1].From
+	logger.InfoContext(r.Context(), "request "+to, "id", id, "from", from, "operator", op)
+	announce(it, from)
+	if to == StatusCompleted {
+		events.Publish(r.Context(), events.Event{Kind: events.RequestCompleted, Subject: it.Board})
+	}
+	api.OK(w, it)
+}
+
+// handleComplete ends a call: POST /api/request/complete?id=&outcome=&note=
+// with outcome completed (default), failed or no_answer.
+func handleComplete(w http.ResponseWriter, r *http.Request) {
+	id, ok := api.QueryInt(w, r, "id")
+	if !ok {
+		return
+	}
+	outcome := r.URL.Query().Get("outcome")
+	switch outcome {
+	case "":
+		outcome = StatusCompleted
+	case StatusCompleted, StatusFailed, StatusNoAnswer:
+	default:
+		api.Invalid(w, "outcome", "outcome must be completed, failed or no_answer")
+		return
+	}
+	doTransition(w, r, id, outcome)
+}
+
+// handleLog searches finished requests, newest first:
+// GET /api/request/log?board=&status=completed,no_answer&q=text&limit=&offset=
+func handleLog(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := api.QueryPage(w, r, 50, 200)
+	if !ok {
+		return
+	}
+	board, ok := queryBoard(w, r)
+	if !ok {
+		return
+	}
+	want := map[string]bool{}
+	if s := r.URL.Query().Get("status"); s != "" {
+		for _, st := range strings.Split(s, ",") {
+			st = strings.TrimSpace(st)
+			if _, known := transitions[st]; known || !isStatus(st) {
+				api.FieldError(w, http.StatusBadRequest, api.CodeBadRequest, "status", "not a final request status: "+st)
+				return
+			}
+			want[st] = true
+		}
+	}
+	needle := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q")))
+	reqMu.Lock()
+	matched := []RequestItem{}
+	for i := len(reqLog) - 1; i >= 0; i-- {
+		it := reqLog[i]
+		if len(want) > 0 && !want[it.Status] || board != "" && it.Board != board {
+			continue
+		}
+		if needle != "" && !strings.Contains(searchText(it), needle) {
+			continue
+		}
+		matched = append(matched, it.public())
+	}
+	reqMu.Unlock()
+	total := len(matched)
+	items := matched[min(offset, total):min(offset+limit, total)]
+	api.OK(w, api.Page{Items: items, Total: total, Limit: limit, Offset: offset})
+}
+
+func isStatus(s string) bool {
+	switch s {
+	case StatusAwaitingPayment, StatusPending, StatusApproved, StatusInCall, StatusCompleted, StatusFailed, StatusNoAnswer, StatusRejected, StatusExpired, StatusMerged:
+		return true
+	}
+	return false
+}
+
+// searchText is what ?q= is matched against: board, notes, masked phone,
+// screening reason and the operators who touched the request.
+func searchText(it *RequestItem) string {
+	parts := []string{it.Board, boardName(it.Board), it.Note, it.OutcomeNote, it.MaskedPhone, it.AutoReason}
+	for _, t := range it.History {
+		parts = append(parts, t.Operator, t.Note)
+	}
+	return strings.ToLower(strings.Join(parts, " "))
+}
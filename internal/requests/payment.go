@@ -0,0 +1,129 @@
+package requests
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/events"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
+)
+
+// Requests on a board with a price wait in reqHeld as awaiting_payment until
+// a donation covering the price arrives. A donation pays for a request when
+// its message contains the request's payment reference. One quoting no
+// reference pays by donor name instead, but only when the name given at
+// submission matches exactly one hold the donation covers; a donor with
+// several must quote a reference. Only the price is taken: anything over
+// it is left for the rest of the event bus (per-dollar quest triggers). A
+// donation naming a quest is meant for the quest and pays for nothing here.
+
+var mPayments = metrics.NewCounter("overlay_request_payments_total", "Paid-request holds by how they ended.", "result")
+
+// refAlphabet leaves out characters that are easy to misread on stream.
+const refAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// newPaymentRefLocked returns a reference like "RQ-7K3FM" not held by any
+// other waiting request.
+func newPaymentRefLocked() string {
+	for {
+		b := make([]byte, 5)
+		for i := range b {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(refAlphabet))))
+			if err != nil {
+				panic(err) // crypto/rand doesn't fail on supported platforms
+			}
+			b[i] = refAlphabet[n.Int64()]
+		}
+		ref := "RQ-" + string(b)
+		taken := false
+		for _, it := range reqHeld {
+			if it.PaymentRef == ref {
+				taken = true
+			}
+		}
+		if !taken {
+			return ref
+		}
+	}
+}
+
+// matchDonationLocked picks the hold a donation pays for, if any.
+func matchDonationLocked(d events.Event) *RequestItem {
+	cents := d.Cents
+	msg := strings.ToUpper(d.Message)
+	for _, it := range reqHeld {
+		if it.PaymentRef != "" && strings.Contains(msg, it.PaymentRef) {
+			if cents < it.PriceCents {
+				return nil // meant for that request, not whatever the donor also holds
+			}
+			return it
+		}
+	}
+	donor := donorKey(d.Donor)
+	if donor == "" {
+		return nil
+	}
+	var match *RequestItem
+	for _, it := range reqHeld {
+		if donorKey(it.Donor) == donor && cents >= it.PriceCents {
+			if match != nil {
+				return nil // ambiguous without a reference
+			}
+			match = it
+		}
+	}
+	return match
+}
+
+func donorKey(s string) string { return strings.ToLower(strings.TrimSpace(s)) }
+
+// handleDonation claims the price of a held request out of a donation that
+// pays for it. It is registered with events.Claim.
+func handleDonation(ctx context.Context, d events.Event) int64 {
+	if d.Kind != events.Donation || d.Subject != "" {
+		return 0
+	}
+	reqMu.Lock()
+	it := matchDonationLocked(d)
+	if it == nil {
+		reqMu.Unlock()
+		return 0
+	}
+	price := it.PriceCents
+	note := fmt.Sprintf("paid $%.2f by %s", float64(price)/100, strings.TrimSpace(d.Donor))
+	it.PaidCents = price
+	transitionLocked(it, StatusPending, "donation", note)
+	id, ref := it.ID, it.PaymentRef
+	reqMu.Unlock()
+	mPayments.Inc("paid")
+	logger.InfoContext(ctx, "request paid", "id", id, "ref", ref, "donor", d.Donor, "cents", price, "donated_cents", d.Cents)
+	return price
+}
+
+func requestsListHeld(board string) []RequestItem {
+	reqMu.Lock()
+	defer reqMu.Unlock()
+	out := make([]RequestItem, 0, len(reqHeld))
+	for _, it := range reqHeld {
+		if board == "" || it.Board == board {
+			out = append(out, it.public())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// handleHeld lists requests waiting for payment: GET /api/request/held[?board=]
+func handleHeld(w http.ResponseWriter, r *http.Request) {
+	board, ok := queryBoard(w, r)
+	if !ok {
+		return
+	}
+	api.OK(w, requestsListHeld(board))
+}
@@ -0,0 +1,54 @@
+package requests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dtorres47/stream-overlay/internal/events"
+)
+
+func TestHandleDonation(t *testing.T) {
+	holds := func() []*RequestItem {
+		return []*RequestItem{
+			{ID: 1, Status: StatusAwaitingPayment, Donor: "Sam", PriceCents: 500, PaymentRef: "RQ-AAAAA"},
+			{ID: 2, Status: StatusAwaitingPayment, Donor: "Sam", PriceCents: 200, PaymentRef: "RQ-BBBBB"},
+			{ID: 3, Status: StatusAwaitingPayment, Donor: "Kit", PriceCents: 200, PaymentRef: "RQ-CCCCC"},
+		}
+	}
+	tests := []struct {
+		name  string
+		d     events.Event
+		paid  int // request ID moved to pending, 0 for none
+		taken int64
+	}{
+		{"reference", events.Event{Donor: "Sam", Message: "for rq-bbbbb", Cents: 200}, 2, 200},
+		{"reference over the price takes only the price", events.Event{Donor: "Sam", Message: "RQ-AAAAA", Cents: 800}, 1, 500},
+		{"reference below the price", events.Event{Donor: "Kit", Message: "RQ-AAAAA", Cents: 300}, 0, 0},
+		{"reference beats donor name", events.Event{Donor: "Kit", Message: "RQ-BBBBB", Cents: 200}, 2, 200},
+		{"single hold by donor", events.Event{Donor: " kit ", Cents: 250}, 3, 200},
+		{"donor covering several holds is ambiguous", events.Event{Donor: "Sam", Cents: 500}, 0, 0},
+		{"donor covering one of several holds", events.Event{Donor: "Sam", Cents: 300}, 2, 200},
+		{"unknown donor", events.Event{Donor: "Lee", Cents: 500}, 0, 0},
+		{"anonymous", events.Event{Cents: 500}, 0, 0},
+		{"donation for a quest", events.Event{Donor: "Kit", Message: "RQ-CCCCC", Cents: 200, Subject: "goat-10x"}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqHeld, reqQueue = holds(), []*RequestItem{}
+			tt.d.Kind = events.Donation
+			if got := handleDonation(context.Background(), tt.d); got != tt.taken {
+				t.Errorf("took %d cents, want %d", got, tt.taken)
+			}
+			var paid int
+			for _, it := range reqQueue {
+				paid = it.ID
+				if it.PaidCents != it.PriceCents {
+					t.Errorf("request %d PaidCents = %d, want %d", it.ID, it.PaidCents, it.PriceCents)
+				}
+			}
+			if paid != tt.paid {
+				t.Errorf("paid request %d, want %d", paid, tt.paid)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package requests
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/auth"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// RoleRevealPhone lets an operator see a caller's full number.
+const RoleRevealPhone = "reveal_phone"
+
+var (
+	mReveals = metrics.NewCounter("overlay_phone_reveals_total", "Full phone numbers shown to operators.")
+	mPurged  = metrics.NewCounter("overlay_phone_purged_total", "Phone numbers deleted after the retention period.")
+)
+
+// Phone numbers are kept AES-256-GCM encrypted, in memory and in state.json,
+// as "v1:" + base64(nonce || ciphertext). The key comes from PHONE_KEY
+// (base64, 32 bytes) or, failing that, the file named by PHONE_KEY_FILE
+// (default phone.key), which is created on first run.
+var (
+	phoneMu      sync.Mutex
+	phoneAEAD    cipher.AEAD
+	phoneHashKey []byte // HMAC key for phoneHash, derived from the same key
+)
+
+const phoneEncPrefix = "v1:"
+
+var errNoPhoneKey = errors.New("phone encryption key not configured")
+
+// SetupPhoneKey loads the phone-number key. It must run before
+// state.LoadState so numbers from older snapshots can be encrypted.
+func SetupPhoneKey() error {
+	key, src, err := loadPhoneKey()
+	if err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	hk := sha256.Sum256(append([]byte("stream-overlay phone hash\x00"), key...))
+	phoneMu.Lock()
+	phoneAEAD = aead
+	phoneHashKey = hk[:]
+	phoneMu.Unlock()
+	logger.Info("phone encryption ready", "key_source", src)
+	return nil
+}
+
+func loadPhoneKey() (key []byte, source string, err error) {
+	if v := strings.TrimSpace(os.Getenv("PHONE_KEY")); v != "" {
+		key, err = base64.StdEncoding.DecodeString(v)
+		if err != nil || len(key) != 32 {
+			return nil, "", errors.New("PHONE_KEY must be 32 bytes, base64-encoded")
+		}
+		return key, "env", nil
+	}
+	file := os.Getenv("PHONE_KEY_FILE")
+	if file == "" {
+		file = "phone.key"
+	}
+	b, err := os.ReadFile(file)
+	switch {
+	case err == nil:
+		key, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+		if err != nil || len(key) != 32 {
+			return nil, "", fmt.Errorf("%s must hold 32 bytes, base64-encoded", file)
+		}
+		return key, file, nil
+	case errors.Is(err, os.ErrNotExist):
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, "", err
+		}
+		if err := os.WriteFile(file, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600); err != nil {
+			return nil, "", err
+		}
+		logger.Warn("generated a new phone encryption key; back it up or numbers in saved state become unreadable", "file", file)
+		return key, file, nil
+	default:
+		return nil, "", err
+	}
+}
+
+func encryptPhone(digits string) (string, error) {
+	phoneMu.Lock()
+	aead := phoneAEAD
+	phoneMu.Unlock()
+	if aead == nil {
+		return "", errNoPhoneKey
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(digits), nil)
+	return phoneEncPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// phoneHash identifies an E.164 number without storing it, for blocklist
+// and duplicate matching. It is keyed so the small number space can't be
+// brute-forced from state.json alone; changing the key orphans old hashes.
+func phoneHash(e164 string) (string, error) {
+	phoneMu.Lock()
+	key := phoneHashKey
+	phoneMu.Unlock()
+	if key == nil {
+		return "", errNoPhoneKey
+	}
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(e164))
+	return hex.EncodeToString(m.Sum(nil)), nil
+}
+
+func decryptPhone(enc string) (string, error) {
+	phoneMu.Lock()
+	aead := phoneAEAD
+	phoneMu.Unlock()
+	if aead == nil {
+		return "", errNoPhoneKey
+	}
+	raw, ok := strings.CutPrefix(enc, phoneEncPrefix)
+	if !ok {
+		return "", errors.New("unknown phone encoding")
+	}
+	b, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil || len(b) < aead.NonceSize() {
+		return "", errors.New("corrupt phone ciphertext")
+	}
+	plain, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("phone ciphertext does not match the key")
+	}
+	return string(plain), nil
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Retention
+// ─────────────────────────────────────────────────────────────────────────────
+
+// StartPhonePurge deletes the number from finished requests once they have
+// been finished for PHONE_RETENTION_DAYS (default 30), checking every
+// interval. The masked number stays for the call log. save is called after
+// any purge, so the numbers don't survive on disk until the next save.
+func StartPhonePurge(interval time.Duration, save func() error) {
+	days := 30
+	if v := os.Getenv("PHONE_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			logger.Warn("bad PHONE_RETENTION_DAYS; using default", "value", v, "default", days)
+		} else {
+			days = n
+		}
+	}
+	retention := time.Duration(days) * 24 * time.Hour
+	purge := func(now time.Time) {
+		if purgePhones(now.Add(-retention).Unix()) == 0 {
+			return
+		}
+		if err := save(); err != nil {
+			logger.Error("state save after phone purge failed", "err", err)
+		}
+	}
+	purge(time.Now())
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for now := range t.C {
+			purge(now)
+		}
+	}()
+}
+
+// purgePhones drops the numbers of requests that finished at or before
+// cutoff and returns how many it dropped. A request with no record of when
+// it finished is left alone rather than purged early.
+func purgePhones(cutoff int64) int {
+	reqMu.Lock()
+	defer reqMu.Unlock()
+	now := time.Now().Unix()
+	n := 0
+	for _, it := range reqLog {
+		if it.PhoneEnc == "" {
+			continue
+		}
+		done := enteredUnix(it, it.Status)
+		if done == 0 {
+			done = it.UpdatedUnix
+		}
+		if done == 0 || done > cutoff {
+			continue
+		}
+		it.PhoneEnc = ""
+		it.PhoneHash = ""
+		it.PhonePurgedUnix = now
+		n++
+	}
+	if n > 0 {
+		mPurged.Add(float64(n))
+		logger.Info("phone numbers purged", "count", n)
+	}
+	return n
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Audited reveal
+// ─────────────────────────────────────────────────────────────────────────────
+
+// RevealAudit is one line of the reveal audit log (PHONE_AUDIT_FILE,
+// default phone-audit.log, JSON lines).
+type RevealAudit struct {
+	Unix       int64  `json:"unix"`
+	Operator   string `json:"operator"`
+	RequestID  int    `json:"request_id"`
+	RemoteAddr string `json:"remote_addr"`
+	HTTPReqID  string `json:"http_request_id,omitempty"`
+}
+
+var auditMu sync.Mutex
+
+func appendAudit(a RevealAudit) error {
+	file := os.Getenv("PHONE_AUDIT_FILE")
+	if file == "" {
+		file = "phone-audit.log"
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// handleReveal returns a request's full number: POST /api/request/reveal?id=
+// It is mounted behind auth.Require(RoleRevealPhone), and nothing is shown
+// unless the audit line was written first.
+func handleReveal(w http.ResponseWriter, r *http.Request) {
+	id, ok := api.QueryInt(w, r, "id")
+	if !ok {
+		return
+	}
+	op, _ := auth.FromContext(r.Context())
+	reqMu.Lock()
+	it, found := findLocked(id)
+	var enc string
+	var purged int64
+	if found {
+		enc, purged = it.PhoneEnc, it.PhonePurgedUnix
+	}
+	reqMu.Unlock()
+	switch {
+	case !found:
+		api.NotFound(w, "request")
+		return
+	case purged != 0:
+		api.Conflict(w, fmt.Sprintf("request %d's number was purged after the retention period", id))
+		return
+	case enc == "":
+		api.Conflict(w, fmt.Sprintf("request %d has no phone number", id))
+		return
+	}
+	phone, err := decryptPhone(enc)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "phone decrypt failed", "id", id, "err", err)
+		api.Error(w, http.StatusInternalServerError, api.CodeInternal, "cannot decrypt phone number")
+		return
+	}
+	audit := RevealAudit{
+		Unix:       time.Now().Unix(),
+		Operator:   op.Name,
+		RequestID:  id,
+		RemoteAddr: r.RemoteAddr,
+		HTTPReqID:  middleware.GetReqID(r.Context()),
+	}
+	if err := appendAudit(audit); err != nil {
+		logger.ErrorContext(r.Context(), "phone reveal audit write failed", "id", id, "err", err)
+		api.Error(w, http.StatusInternalServerError, api.CodeInternal, "cannot write audit log; number not revealed")
+		return
+	}
+	mReveals.Inc()
+	logger.InfoContext(r.Context(), "phone revealed", "id", id, "operator", op.Name)
+	api.OK(w, map[string]any{"id": id, "phone": phone})
+}
@@ -0,0 +1,252 @@
+package requests
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+)
+
+// Region describes how numbers are written in one country. Lengths are of
+// the national significant number: the digits after the country code, with
+// any trunk prefix removed.
+type Region struct {
+	Code        string `json:"code"` // ISO 3166-1 alpha-2
+	Name        string `json:"name"`
+	CountryCode string `json:"country_code"`
+
+	trunk    string   // dialled before national numbers at home (the UK's 0)
+	min, max int      // national significant number length
+	premium  []string // national-number prefixes billed at premium rates
+	groups   []int    // digit grouping for masked display
+	sep      string
+}
+
+// regions are the countries whose numbers we accept. NANP members share
+// +1 and one numbering plan, so a +1 number is parsed by the first entry
+// and labelled with its country afterwards (see nanpCountries).
+var regions = []Region{
+	{Code: "US", Name: "United States", CountryCode: "1", trunk: "1", min: 10, max: 10, premium: []string{"900", "976"}, groups: []int{3, 3, 4}, sep: "-"},
+	{Code: "CA", Name: "Canada", CountryCode: "1", trunk: "1", min: 10, max: 10, premium: []string{"900", "976"}, groups: []int{3, 3, 4}, sep: "-"},
+	{Code: "GB", Name: "United Kingdom", CountryCode: "44", trunk: "0", min: 9, max: 10, premium: []string{"9", "871", "872", "873"}, groups: []int{4, 6}, sep: " "},
+	{Code: "IE", Name: "Ireland", CountryCode: "353", trunk: "0", min: 7, max: 9, premium: []string{"15"}, groups: []int{2, 3, 4}, sep: " "},
+	{Code: "DE", Name: "Germany", CountryCode: "49", trunk: "0", min: 6, max: 13, premium: []string{"900", "137"}, groups: []int{3, 10}, sep: " "},
+	{Code: "FR", Name: "France", CountryCode: "33", trunk: "0", min: 9, max: 9, premium: []string{"89"}, groups: []int{1, 2, 2, 2, 2}, sep: " "},
+	{Code: "ES", Name: "Spain", CountryCode: "34", min: 9, max: 9, premium: []string{"803", "806", "807", "905"}, groups: []int{3, 3, 3}, sep: " "},
+	{Code: "IT", Name: "Italy", CountryCode: "39", min: 6, max: 11, premium: []string{"89"}, groups: []int{3, 8}, sep: " "},
+	{Code: "NL", Name: "Netherlands", CountryCode: "31", trunk: "0", min: 9, max: 9, premium: []string{"906", "909"}, groups: []int{2, 3, 4}, sep: " "},
+	{Code: "MX", Name: "Mexico", CountryCode: "52", min: 10, max: 10, premium: []string{"900"}, groups: []int{3, 3, 4}, sep: " "},
+	{Code: "BR", Name: "Brazil", CountryCode: "55", trunk: "0", min: 10, max: 11, premium: []string{"900"}, groups: []int{2, 5, 4}, sep: " "},
+	{Code: "AU", Name: "Australia", CountryCode: "61", trunk: "0", min: 9, max: 9, premium: []string{"190"}, groups: []int{1, 4, 4}, sep: " "},
+	{Code: "NZ", Name: "New Zealand", CountryCode: "64", trunk: "0", min: 8, max: 10, premium: []string{"900"}, groups: []int{2, 3, 5}, sep: " "},
+	{Code: "JP", Name: "Japan", CountryCode: "81", trunk: "0", min: 9, max: 10, premium: []string{"990"}, groups: []int{2, 4, 4}, sep: "-"},
+	{Code: "IN", Name: "India", CountryCode: "91", trunk: "0", min: 10, max: 10, premium: []string{"1900"}, groups: []int{5, 5}, sep: " "},
+}
+
+// emergencyNumbers can never be submitted, whatever the region.
+var emergencyNumbers = map[string]bool{
+	"911": true, "112": true, "999": true, "000": true, "110": true,
+	"119": true, "100": true, "101": true, "102": true, "15": true, "17": true, "18": true,
+}
+
+func lookupRegion(code string) (Region, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	for _, rg := range regions {
+		if rg.Code == code {
+			return rg, true
+		}
+	}
+	return Region{}, false
+}
+
+// DefaultRegion is where numbers written without a country code are
+// assumed to be: PHONE_DEFAULT_REGION, or US.
+func DefaultRegion() Region {
+	if v := os.Getenv("PHONE_DEFAULT_REGION"); v != "" {
+		if rg, ok := lookupRegion(v); ok {
+			return rg
+		}
+		logger.Warn("unknown PHONE_DEFAULT_REGION; using US", "value", v)
+	}
+	rg, _ := lookupRegion("US")
+	return rg
+}
+
+// PhoneNumber is a parsed, validated number.
+type PhoneNumber struct {
+	Region Region
+	NSN    string // national significant number
+}
+
+// E164 is the canonical +<country code><number> form.
+func (p PhoneNumber) E164() string { return "+" + p.Region.CountryCode + p.NSN }
+
+// Masked shows the country code and the last four digits, grouped the way
+// the country writes them: "+1 ***-***-4567", "+44 **** **4567".
+func (p PhoneNumber) Masked() string {
+	keep := 4
+	if len(p.NSN) <= 6 {
+		keep = 2
+	}
+	masked := strings.Repeat("*", len(p.NSN)-keep) + p.NSN[len(p.NSN)-keep:]
+	var parts []string
+	rest := masked
+	for i, g := range p.Region.groups {
+		if i == len(p.Region.groups)-1 || len(rest) <= g {
+			break
+		}
+		parts = append(parts, rest[:g])
+		rest = rest[g:]
+	}
+	parts = append(parts, rest)
+	return "+" + p.Region.CountryCode + " " + strings.Join(parts, p.Region.sep)
+}
+
+// phoneError is a number we understood well enough to refuse.
+type phoneError struct{ msg string }
+
+func (e *phoneError) Error() string { return e.msg }
+
+var errPhoneNoDigits = &phoneError{"phone number has no digits"}
+
+// parsePhone reads a number as a caller might type it. A leading + or 00
+// means it includes the country code; otherwise it is taken to be a
+// national number in def.
+func parsePhone(raw string, def Region) (PhoneNumber, error) {
+	var b strings.Builder
+	intl := false
+	for i, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			intl = true
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return PhoneNumber{}, &phoneError{"phone number may only contain digits, spaces, + - . ( )"}
+		}
+	}
+	digits := b.String()
+	if digits == "" {
+		return PhoneNumber{}, errPhoneNoDigits
+	}
+	if !intl && strings.HasPrefix(digits, "00") {
+		intl, digits = true, digits[2:]
+	}
+	if !intl && emergencyNumbers[digits] {
+		return PhoneNumber{}, &phoneError{"emergency numbers can't be submitted"}
+	}
+
+	rg := def
+	nsn := digits
+	if intl {
+		found := false
+		for n := 1; n <= 3 && n < len(digits); n++ {
+			if r, ok := regionByCountryCode(digits[:n]); ok {
+				rg, nsn, found = r, digits[n:], true
+				break
+			}
+		}
+		if !found {
+			return PhoneNumber{}, &phoneError{"unsupported country code"}
+		}
+		if rg.trunk == "0" {
+			nsn = strings.TrimPrefix(nsn, "0") // +44 (0)20… is common
+		}
+	} else if rg.trunk != "" && strings.HasPrefix(nsn, rg.trunk) && len(nsn) > rg.min {
+		nsn = nsn[len(rg.trunk):]
+	}
+
+	if emergencyNumbers[nsn] {
+		return PhoneNumber{}, &phoneError{"emergency numbers can't be submitted"}
+	}
+	if len(nsn) < rg.min || len(nsn) > rg.max {
+		return PhoneNumber{}, &phoneError{"not a valid " + rg.Name + " phone number length"}
+	}
+	if rg.CountryCode == "1" {
+		if !validNANP(nsn) {
+			return PhoneNumber{}, &phoneError{"not a valid North American number: area code and exchange must start with 2-9"}
+		}
+		rg = nanpRegion(rg, nsn)
+	}
+	for _, p := range rg.premium {
+		if strings.HasPrefix(nsn, p) {
+			return PhoneNumber{}, &phoneError{"premium-rate numbers are not allowed"}
+		}
+	}
+	return PhoneNumber{Region: rg, NSN: nsn}, nil
+}
+
+// validNANP checks a ten-digit NANP number has the NXX-NXX-XXXX shape: the
+// area code and exchange start with 2-9, and the area code isn't an N11
+// service code.
+func validNANP(nsn string) bool {
+	return nsn[0] >= '2' && nsn[3] >= '2' && nsn[1:3] != "11"
+}
+
+// nanpCountries maps the area codes of NANP members other than the US to
+// their ISO codes; every other area code is American.
+var nanpCountries = map[string]string{
+	// Canada
+	"204": "CA", "226": "CA", "236": "CA", "249": "CA", "250": "CA", "257": "CA", "263": "CA", "289": "CA",
+	"306": "CA", "343": "CA", "354": "CA", "365": "CA", "367": "CA", "368": "CA", "382": "CA", "387": "CA",
+	"403": "CA", "416": "CA", "418": "CA", "428": "CA", "431": "CA", "437": "CA", "438": "CA", "450": "CA",
+	"460": "CA", "468": "CA", "474": "CA", "506": "CA", "514": "CA", "519": "CA", "548": "CA", "579": "CA",
+	"581": "CA", "584": "CA", "587": "CA", "600": "CA", "604": "CA", "613": "CA", "639": "CA", "647": "CA",
+	"672": "CA", "683": "CA", "705": "CA", "709": "CA", "742": "CA", "753": "CA", "778": "CA", "780": "CA",
+	"782": "CA", "807": "CA", "819": "CA", "825": "CA", "867": "CA", "873": "CA", "879": "CA", "902": "CA",
+	"905": "CA",
+	// Caribbean and Atlantic
+	"242": "BS", "246": "BB", "264": "AI", "268": "AG", "284": "VG", "345": "KY", "441": "BM", "473": "GD",
+	"649": "TC", "658": "JM", "664": "MS", "721": "SX", "758": "LC", "767": "DM", "784": "VC", "809": "DO",
+	"829": "DO", "849": "DO", "868": "TT", "869": "KN", "876": "JM",
+	// US territories with their own country codes
+	"340": "VI", "670": "MP", "671": "GU", "684": "AS", "787": "PR", "939": "PR",
+}
+
+// nanpRegion labels rg, a +1 region, with the country nsn's area code
+// belongs to.
+func nanpRegion(rg Region, nsn string) Region {
+	code := nanpCountries[nsn[:3]]
+	if code == "" {
+		code = "US"
+	}
+	if code == rg.Code {
+		return rg
+	}
+	if known, ok := lookupRegion(code); ok {
+		return known
+	}
+	rg.Code, rg.Name = code, "North American Numbering Plan"
+	return rg
+}
+
+func regionByCountryCode(cc string) (Region, bool) {
+	for _, rg := range regions {
+		if rg.CountryCode == cc {
+			return rg, true
+		}
+	}
+	return Region{}, false
+}
+
+// writePhoneErr maps parsePhone failures onto a 422 on ?phone=.
+func writePhoneErr(w http.ResponseWriter, err error) {
+	var pe *phoneError
+	if errors.As(err, &pe) {
+		api.Invalid(w, "phone", pe.msg)
+		return
+	}
+	api.Error(w, http.StatusInternalServerError, api.CodeInternal, err.Error())
+}
+
+// handleRegions lists the countries numbers may come from:
+// GET /api/request/regions
+func handleRegions(w http.ResponseWriter, r *http.Request) {
+	out := append([]Region{}, regions...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	api.OK(w, map[string]any{"default": DefaultRegion().Code, "regions": out})
+}
@@ -0,0 +1,58 @@
+package requests
+
+import "testing"
+
+func TestParsePhone(t *testing.T) {
+	us, _ := lookupRegion("US")
+	gb, _ := lookupRegion("GB")
+	es, _ := lookupRegion("ES")
+	tests := []struct {
+		name    string
+		raw     string
+		def     Region
+		e164    string
+		region  string
+		wantErr bool
+	}{
+		{"us local", "(415) 555-2671", us, "+14155552671", "US", false},
+		{"us with trunk prefix", "1-415-555-2671", us, "+14155552671", "US", false},
+		{"us international", "+1 415 555 2671", gb, "+14155552671", "US", false},
+		{"us 00 prefix", "0014155552671", gb, "+14155552671", "US", false},
+		{"canadian area code", "+1 604 555 0100", us, "+16045550100", "CA", false},
+		{"canadian number dialled locally", "416.555.0199", us, "+14165550199", "CA", false},
+		{"caribbean area code", "+1 876 555 0100", us, "+18765550100", "JM", false},
+		{"area code starting with 0", "015-555-2671", us, "", "", true},
+		{"area code starting with 1", "+1 115 555 2671", us, "", "", true},
+		{"exchange starting with 0", "415-055-2671", us, "", "", true},
+		{"exchange starting with 1", "415-155-2671", us, "", "", true},
+		{"n11 area code", "411-555-2671", us, "", "", true},
+		{"us premium", "900-555-2671", us, "", "", true},
+		{"us too short", "555-2671", us, "", "", true},
+		{"uk trunk prefix", "020 7946 0958", gb, "+442079460958", "GB", false},
+		{"uk international with (0)", "+44 (0)20 7946 0958", us, "+442079460958", "GB", false},
+		{"uk premium", "0909 879 0000", gb, "", "", true},
+		{"spain has no trunk prefix", "912 345 678", es, "+34912345678", "ES", false},
+		{"emergency local", "911", us, "", "", true},
+		{"emergency after country code", "+44 999", us, "", "", true},
+		{"unsupported country code", "+999 1234 5678", us, "", "", true},
+		{"letters", "415-555-CALL", us, "", "", true},
+		{"no digits", " - ", us, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePhone(tt.raw, tt.def)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsePhone(%q) = %s, want an error", tt.raw, got.E164())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePhone(%q): %v", tt.raw, err)
+			}
+			if got.E164() != tt.e164 || got.Region.Code != tt.region {
+				t.Errorf("parsePhone(%q) = %s (%s), want %s (%s)", tt.raw, got.E164(), got.Region.Code, tt.e164, tt.region)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package requests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/catalog"
+	"github.com/dtorres47/stream-overlay/internal/ws"
+)
+
+// Pending requests are served in reqQueue order, and each board's queue is
+// its requests in that order. Operators can move a request past its
+// neighbours on the same board or pin it; pinned requests stay ahead of
+// unpinned ones. Moves and pins broadcast REQUEST_UPDATE carrying the
+// request's board queue in its new order; edits broadcast only for approved
+// requests, the ones overlays show.
+
+// pendingLocked returns request id if it is pending, or a 404/409 error.
+func pendingLocked(id int) (*RequestItem, error) {
+	it, ok := findLocked(id)
+	if !ok {
+		return nil, errNotFound
+	}
+	if it.Status != StatusPending {
+		return nil, fmt.Errorf("request %d is %s; only pending requests can be reordered", id, it.Status)
+	}
+	return it, nil
+}
+
+// editableLocked returns request id if it is pending or approved, or a
+// 404/409 error.
+func editableLocked(id int) (*RequestItem, error) {
+	it, ok := findLocked(id)
+	if !ok {
+		return nil, errNotFound
+	}
+	if it.Status != StatusPending && it.Status != StatusApproved {
+		return nil, fmt.Errorf("request %d is %s; only pending and approved requests can be edited", id, it.Status)
+	}
+	return it, nil
+}
+
+// rehomeLocked checks that it may move to board b, screening it there as
+// if it had been submitted to b: not onto a paid board it hasn't paid for,
+// not onto a blocked board or a full one, and not onto a board where it
+// would duplicate another request.
+func rehomeLocked(it *RequestItem, b catalog.Board) error {
+	if b.ID == it.Board {
+		return nil
+	}
+	if b.PriceCents > 0 {
+		return fmt.Errorf("%s is a paid board; requests can't be moved onto it", b.Name)
+	}
+	if it.Status == StatusApproved {
+		if err := checkCapacityLocked(b.ID); err != nil {
+			return err
+		}
+	}
+	prev := it.Board
+	it.Board = b.ID
+	defer func() { it.Board = prev }()
+	if e, ok := blockedBy(it); ok {
+		return fmt.Errorf("request %d is blocked on %s (%s)", it.ID, b.Name, e.Kind)
+	}
+	if cfg := GetDuplicates(); cfg.WindowMinutes > 0 {
+		cutoff := time.Now().Add(-time.Duration(cfg.WindowMinutes) * time.Minute).Unix()
+		if orig := findDuplicateLocked(it, cfg, cutoff); orig != nil {
+			return fmt.Errorf("request %d would duplicate #%d on %s", it.ID, orig.ID, b.Name)
+		}
+	}
+	return nil
+}
+
+func writeQueueErr(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNotFound) {
+		api.NotFound(w, "request")
+		return
+	}
+	api.Conflict(w, err.Error())
+}
+
+func queueIndexLocked(it *RequestItem) int {
+	for i, q := range reqQueue {
+		if q == it {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveLocked swaps it with the nearest request on the same board in
+// direction dir (-1 up, +1 down), without crossing the pinned boundary.
+func moveLocked(it *RequestItem, dir int) bool {
+	i := queueIndexLocked(it)
+	for j := i + dir; j >= 0 && j < len(reqQueue); j += dir {
+		o := reqQueue[j]
+		if o.Board != it.Board {
+			continue
+		}
+		if o.Pinned != it.Pinned {
+			return false
+		}
+		reqQueue[i], reqQueue[j] = o, it
+		return true
+	}
+	return false
+}
+
+// pinLocked moves it to the top of the queue, or unpins it to just below
+// the remaining pinned requests.
+func pinLocked(it *RequestItem, pinned bool) {
+	reqQueue = removeItem(reqQueue, it)
+	it.Pinned = pinned
+	at := 0
+	if !pinned {
+		for at < len(reqQueue) && reqQueue[at].Pinned {
+			at++
+		}
+	}
+	reqQueue = append(reqQueue[:at], append([]*RequestItem{it}, reqQueue[at:]...)...)
+}
+
+// orderUpdateLocked is the REQUEST_UPDATE sent when it moves: its pin and
+// the IDs of its board's pending requests in serving order.
+func orderUpdateLocked(it *RequestItem) ws.WSMsg {
+	order := []int{}
+	for _, o := range reqQueue {
+		if o.Board == it.Board {
+			order = append(order, o.ID)
+		}
+	}
+	return ws.WSMsg{Type: "REQUEST_UPDATE", Data: map[string]any{
+		"id": it.ID, "status": it.Status, "board": it.Board, "pinned": it.Pinned, "order": order,
+	}}
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// HTTP
+// ─────────────────────────────────────────────────────────────────────────────
+
+// handleEdit changes a pending or approved request: POST
+// /api/request/edit?id= with a JSON body of the fields to change (note,
+// board, flagged).
+func handleEdit(w http.ResponseWriter, r *http.Request) {
+	id, ok := api.QueryInt(w, r, "id")
+	if !ok {
+		return
+	}
+	var in struct {
+		Note    *string `json:"note"`
+		Board   *string `json:"board"`
+		Flagged *bool   `json:"flagged"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		api.Error(w, http.StatusBadRequest, api.CodeBadRequest, "invalid JSON payload")
+		return
+	}
+	var board catalog.Board
+	if in.Board != nil {
+		b, found := catalog.GetBoard(strings.TrimSpace(*in.Board))
+		if !found {
+			api.Invalid(w, "board", "unknown board "+*in.Board)
+			return
+		}
+		board = b
+	}
+
+	reqMu.Lock()
+	it, err := editableLocked(id)
+	if err != nil {
+		reqMu.Unlock()
+		writeQueueErr(w, err)
+		return
+	}
+	if in.Board != nil && board.PhoneRequired && it.PhoneEnc == "" {
+		reqMu.Unlock()
+		api.Invalid(w, "board", board.Name+" requests need a phone number")
+		return
+	}
+	if in.Board != nil {
+		if err := rehomeLocked(it, board); err != nil {
+			reqMu.Unlock()
+			writeQueueErr(w, err)
+			return
+		}
+	}
+	if in.Note != nil {
+		it.Note = strings.TrimSpace(*in.Note)
+	}
+	if in.Board != nil {
+		it.Board = board.ID
+	}
+	if in.Flagged != nil {
+		it.Flagged = *in.Flagged
+	}
+	it.UpdatedUnix = time.Now().Unix()
+	snap := it.public()
+	reqMu.Unlock()
+
+	logger.InfoContext(r.Context(), "request edited", "id", id, "board", snap.Board, "flagged", snap.Flagged, "operator", operatorFrom(r))
+	if snap.Status == StatusApproved {
+		ws.Broadcast(ws.WSMsg{Type: "REQUEST_UPDATE", Data: OverlayView(snap)})
+	}
+	api.OK(w, snap)
+}
+
+// handleMove moves a pending request one place within its board's queue:
+// POST /api/request/move?id=&dir=up|down
+func handleMove(w http.ResponseWriter, r *http.Request) {
+	id, ok := api.QueryInt(w, r, "id")
+	if !ok {
+		return
+	}
+	dir := 0
+	switch r.URL.Query().Get("dir") {
+	case "up":
+		dir = -1
+	case "down":
+		dir = 1
+	default:
+		api.Invalid(w, "dir", "dir must be up or down")
+		return
+	}
+	reqMu.Lock()
+	it, err := pendingLocked(id)
+	if err != nil {
+		reqMu.Unlock()
+		writeQueueErr(w, err)
+		return
+	}
+	if !moveLocked(it, dir) {
+		reqMu.Unlock()
+		api.Conflict(w, fmt.Sprintf("request %d can't move %s any further", id, r.URL.Query().Get("dir")))
+		return
+	}
+	snap, msg := it.public(), orderUpdateLocked(it)
+	reqMu.Unlock()
+	ws.Broadcast(msg)
+	logger.InfoContext(r.Context(), "request moved", "id", id, "dir", dir, "operator", operatorFrom(r))
+	api.OK(w, snap)
+}
+
+// handlePin pins a pending request to the top of the queue:
+// POST /api/request/pin?id=[&pinned=false]
+func handlePin(w http.ResponseWriter, r *http.Request) {
+	id, ok := api.QueryInt(w, r, "id")
+	if !ok {
+		return
+	}
+	pinned := r.URL.Query().Get("pinned") != "false"
+	reqMu.Lock()
+	it, err := pendingLocked(id)
+	if err != nil {
+		reqMu.Unlock()
+		writeQueueErr(w, err)
+		return
+	}
+	pinLocked(it, pinned)
+	snap, msg := it.public(), orderUpdateLocked(it)
+	reqMu.Unlock()
+	ws.Broadcast(msg)
+	logger.InfoContext(r.Context(), "request pinned", "id", id, "pinned", pinned, "operator", operatorFrom(r))
+	api.OK(w, snap)
+}
+
+// handleBulkReject rejects every pending request matching all the given
+// filters: POST /api/request/bulk-reject with JSON {board,
+// older_than_minutes, flagged, note}. At least one filter is required.
+func handleBulkReject(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Board            string `json:"board"`
+		OlderThanMinutes int    `json:"older_than_minutes"`
+		Flagged          bool   `json:"flagged"`
+		Note             string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		api.Error(w, http.StatusBadRequest, api.CodeBadRequest, "invalid JSON payload")
+		return
+	}
+	board := ""
+	if in.Board != "" {
+		b, found := catalog.GetBoard(in.Board)
+		if !found {
+			api.Invalid(w, "board", "unknown board "+in.Board)
+			return
+		}
+		board = b.ID
+	}
+	switch {
+	case in.OlderThanMinutes < 0:
+		api.Invalid(w, "older_than_minutes", "older_than_minutes must not be negative")
+		return
+	case board == "" && in.OlderThanMinutes == 0 && !in.Flagged:
+		api.Invalid(w, "board", "give at least one of board, older_than_minutes or flagged")
+		return
+	}
+	op := operatorFrom(r)
+	note := strings.TrimSpace(in.Note)
+	if note == "" {
+		note = "bulk reject"
+	}
+	cutoff := time.Now().Add(-time.Duration(in.OlderThanMinutes) * time.Minute).Unix()
+
+	reqMu.Lock()
+	var match []*RequestItem
+	for _, it := range reqQueue {
+		if (board == "" || it.Board == board) &&
+			(in.OlderThanMinutes == 0 || it.CreatedUnix <= cutoff) &&
+			(!in.Flagged || it.Flagged) {
+			match = append(match, it)
+		}
+	}
+	ids := make([]int, 0, len(match))
+	done := make([]RequestItem, 0, len(match))
+	for _, it := range match {
+		transitionLocked(it, StatusRejected, op, note)
+		ids = append(ids, it.ID)
+		done = append(done, it.public())
+	}
+	reqMu.Unlock()
+
+	for _, it := range done {
+		announce(it, StatusPending)
+	}
+	logger.InfoContext(r.Context(), "requests bulk rejected", "count", len(ids), "board", board, "older_than_minutes", in.OlderThanMinutes, "flagged", in.Flagged, "operator", op)
+	api.OK(w, map[string]any{"rejected": ids})
+}
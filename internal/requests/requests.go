@@ -1,95 +1,115 @@
 package requests
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
-	"strconv"
+	"sort"
 	"strings"
 	"sync"
 	"time"
-	"unicode"
 
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/auth"
+	"github.com/dtorres47/stream-overlay/internal/catalog"
+	"github.com/dtorres47/stream-overlay/internal/events"
+	"github.com/dtorres47/stream-overlay/internal/logging"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
 	"github.com/dtorres47/stream-overlay/internal/ws"
 	"github.com/go-chi/chi/v5"
 )
 
+var logger = logging.For("requests")
+
 type RequestItem struct {
-	ID          int    `json:"id"`
-	Board       string `json:"board"`
-	Phone       string `json:"phone"`
-	MaskedPhone string `json:"masked_phone"`
-	Note        string `json:"note"`
-	Status      string `json:"status"`
-	CreatedUnix int64  `json:"created_unix"`
+	ID          int          `json:"id"`
+	Board       string       `json:"board"` // catalog board ID
+	MaskedPhone string       `json:"masked_phone"`
+	PhoneRegion string       `json:"phone_region,omitempty"` // ISO country of the number
+	Note        string       `json:"note"`
+	Status      string       `json:"status"`
+	CreatedUnix int64        `json:"created_unix"`
+	UpdatedUnix int64        `json:"updated_unix,omitempty"`
+	OutcomeNote string       `json:"outcome_note,omitempty"` // note given with the final transition
+	History     []Transition `json:"history,omitempty"`
+
+	// Set by operators while pending; see queue.go.
+	Pinned  bool `json:"pinned,omitempty"`  // kept ahead of unpinned requests
+	Flagged bool `json:"flagged,omitempty"` // marked for review or bulk rejection
+
+	// Paid boards; see payment.go.
+	Donor      string `json:"donor,omitempty"`       // who is expected to pay
+	PriceCents int64  `json:"price_cents,omitempty"` // the board's price at submission
+	PaymentRef string `json:"payment_ref,omitempty"` // quote in the donation message
+	PaidCents  int64  `json:"paid_cents,omitempty"`
+
+	// Set by screening on submit; see screen.go.
+	AutoReason  string `json:"auto_reason,omitempty"`  // why it was rejected or merged on arrival
+	DuplicateOf int    `json:"duplicate_of,omitempty"` // the request it was merged into
+	Duplicates  int    `json:"duplicates,omitempty"`   // later submissions merged into this one
+
+	// PhoneEnc is the encrypted number; see phone.go. It is persisted but
+	// never sent to clients, which see HasPhone instead.
+	PhoneEnc        string `json:"phone_enc,omitempty"`
+	PhoneHash       string `json:"phone_hash,omitempty"` // keyed hash of the E.164 form
+	HasPhone        bool   `json:"has_phone"`
+	PhonePurgedUnix int64  `json:"phone_purged_unix,omitempty"`
+
+	// Token lets the submitter look the request up; see status.go. Like
+	// the number, it is persisted but never sent back after submit.
+	Token string `json:"tracking_token,omitempty"`
+
+	// LegacyPhone is the plaintext number from snapshots written before
+	// encryption; SetState encrypts and clears it.
+	LegacyPhone string `json:"phone,omitempty"`
+}
+
+// public is the copy of it that API responses carry.
+func (it RequestItem) public() RequestItem {
+	it.HasPhone = it.PhoneEnc != ""
+	it.PhoneEnc = ""
+	it.PhoneHash = ""
+	it.LegacyPhone = ""
+	it.Token = ""
+	return it
 }
 
 var (
 	reqMu     = sync.Mutex{}
 	reqSeq    = 0
-	reqQueue  = []*RequestItem{}
-	reqActive = map[int]*RequestItem{}
+	reqHeld   = []*RequestItem{}       // awaiting_payment
+	reqQueue  = []*RequestItem{}       // pending
+	reqActive = map[int]*RequestItem{} // approved or in_call
+	reqLog    = []*RequestItem{}       // finished, oldest first
 )
 
-func digitsOnly(s string) string {
-	var b strings.Builder
-	for _, r := range s {
-		if unicode.IsDigit(r) {
-			b.WriteRune(r)
-		}
-	}
-	return b.String()
-}
-
-func maskPhone(d string) string {
-	if d == "" {
-		return ""
-	}
-	if len(d) == 10 {
-		return fmt.Sprintf("***-***-%s", d[6:])
-	}
-	if len(d) == 11 && d[0] == '1' {
-		return fmt.Sprintf("1-***-***-%s", d[7:])
-	}
-	last4 := d
-	if len(d) > 4 {
-		last4 = d[len(d)-4:]
-	}
-	return fmt.Sprintf("***-%s", last4)
-}
+var _ = metrics.NewGaugeFunc("overlay_request_queue_depth", "Call requests awaiting approval.", func() float64 {
+	return float64(PendingCount())
+})
 
-func requestsListPending() []RequestItem {
+// requestsListPending returns the queue for board, or every board's if
+// board is "".
+func requestsListPending(board string) []RequestItem {
 	reqMu.Lock()
 	defer reqMu.Unlock()
 	out := make([]RequestItem, 0, len(reqQueue))
 	for _, it := range reqQueue {
-		if it.Status == "pending" {
-			out = append(out, *it)
+		if it.Status == StatusPending && (board == "" || it.Board == board) {
+			out = append(out, it.public())
 		}
 	}
 	return out
 }
 
-func requestsListActive() []RequestItem {
+func requestsListActive(board string) []RequestItem {
 	reqMu.Lock()
 	defer reqMu.Unlock()
 	out := make([]RequestItem, 0, len(reqActive))
 	for _, it := range reqActive {
-		out = append(out, *it)
-	}
-	return out
-}
-
-func requestFind(id int) (*RequestItem, bool) {
-	reqMu.Lock()
-	defer reqMu.Unlock()
-	for _, it := range reqQueue {
-		if it.ID == id {
-			return it, true
+		if board == "" || it.Board == board {
+			out = append(out, it.public())
 		}
 	}
-	it, ok := reqActive[id]
-	return it, ok
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
 }
 
 // RegisterRoutes mounts all /api/request/* endpoints.
@@ -97,106 +117,163 @@ func RegisterRoutes(r chi.Router) {
 	r.Get("/api/request/submit", handleSubmit)
 	r.Get("/api/request/queue", handleQueue)
 	r.Get("/api/request/active", handleActive)
-	r.Post("/api/request/approve", handleApprove)
-	r.Post("/api/request/reject", handleReject)
+	r.Get("/api/request/held", handleHeld)
+	r.Get("/api/request/status/{token}", handleStatus)
+	r.Get("/api/request/log", handleLog)
+	r.Get("/api/request/boards", handleBoards)
+	r.Get("/api/request/regions", handleRegions)
+	r.Get("/api/request/blocklist", handleGetBlocklist)
+	r.Post("/api/request/blocklist", handleBlock)
+	r.Post("/api/request/blocklist/remove", handleUnblock)
+	r.Get("/api/request/duplicates", handleGetDuplicates)
+	r.Put("/api/request/duplicates", handlePutDuplicates)
+	r.Post("/api/request/edit", handleEdit)
+	r.Post("/api/request/move", handleMove)
+	r.Post("/api/request/pin", handlePin)
+	r.Post("/api/request/bulk-reject", handleBulkReject)
+	r.Post("/api/request/mark-paid", moveHandler(StatusPending))
+	r.Post("/api/request/approve", moveHandler(StatusApproved))
+	r.Post("/api/request/reject", moveHandler(StatusRejected))
+	r.Post("/api/request/start", moveHandler(StatusInCall))
 	r.Post("/api/request/complete", handleComplete)
+	r.With(auth.Require(RoleRevealPhone)).Post("/api/request/reveal", handleReveal)
+	events.Claim(handleDonation)
 }
 
 func handleSubmit(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
-	board := strings.TrimSpace(q.Get("board"))
-	phone := digitsOnly(q.Get("phone"))
+	rawBoard := strings.TrimSpace(q.Get("board"))
+	rawPhone := strings.TrimSpace(q.Get("phone"))
 	note := strings.TrimSpace(q.Get("note"))
-	if board == "" && phone == "" {
-		http.Error(w, "provide at least ?board= or ?phone=", http.StatusBadRequest)
+	if rawBoard == "" {
+		api.Invalid(w, "board", "missing ?board=; see /api/request/boards")
+		return
+	}
+	board, ok := catalog.GetBoard(rawBoard)
+	if !ok {
+		api.Invalid(w, "board", "unknown board "+rawBoard)
+		return
+	}
+	if board.PhoneRequired && rawPhone == "" {
+		api.Invalid(w, "phone", board.Name+" requests need a phone number")
 		return
 	}
 	item := &RequestItem{
-		Board:       board,
-		Phone:       phone,
-		MaskedPhone: maskPhone(phone),
+		Board:       board.ID,
 		Note:        note,
-		Status:      "pending",
+		Status:      StatusPending,
 		CreatedUnix: time.Now().Unix(),
+		Donor:       strings.TrimSpace(q.Get("donor")),
+		Token:       newTrackingToken(),
+	}
+	if board.PriceCents > 0 {
+		item.Status = StatusAwaitingPayment
+		item.PriceCents = board.PriceCents
+	}
+	if rawPhone != "" {
+		region := DefaultRegion()
+		if code := q.Get("region"); code != "" {
+			rg, ok := lookupRegion(code)
+			if !ok {
+				api.Invalid(w, "region", "unsupported region "+code)
+				return
+			}
+			region = rg
+		}
+		num, err := parsePhone(rawPhone, region)
+		if err != nil {
+			writePhoneErr(w, err)
+			return
+		}
+		item.MaskedPhone = num.Masked()
+		item.PhoneRegion = num.Region.Code
+		enc, err := encryptPhone(num.E164())
+		if err == nil {
+			item.PhoneHash, err = phoneHash(num.E164())
+		}
+		if err != nil {
+			logger.ErrorContext(r.Context(), "phone encrypt failed", "err", err)
+			api.Error(w, http.StatusInternalServerError, api.CodeInternal, "cannot store phone number")
+			return
+		}
+		item.PhoneEnc = enc
 	}
 	reqMu.Lock()
 	reqSeq++
 	item.ID = reqSeq
-	reqQueue = append(reqQueue, item)
+	if item.Status == StatusAwaitingPayment {
+		item.PaymentRef = newPaymentRefLocked()
+	}
+	fileLocked(item)
+	var into *RequestItem
+	if orig := screenLocked(item, time.Now()); orig != nil {
+		o := orig.public()
+		into = &o
+	}
+	snap := item.public()
+	receipt := SubmitReceipt{
+		Token:        item.Token,
+		StatusPage:   "/request-status?token=" + item.Token,
+		PublicStatus: statusLocked(item),
+		PaymentRef:   item.PaymentRef,
+		PriceCents:   item.PriceCents,
+	}
 	reqMu.Unlock()
+	logger.InfoContext(r.Context(), "request submitted", "id", snap.ID, "board", board.ID, "masked_phone", snap.MaskedPhone, "note", note, "status", snap.Status, "auto_reason", snap.AutoReason, "payment_ref", snap.PaymentRef)
+	if into != nil {
+		logger.InfoContext(r.Context(), "request merged into duplicate", "id", snap.ID, "into", into.ID, "duplicates", into.Duplicates)
+		if into.Status == StatusApproved || into.Status == StatusInCall {
+			ws.Broadcast(ws.WSMsg{Type: "REQUEST_UPDATE", Data: OverlayView(*into)})
+		}
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(item)
+	api.Created(w, receipt)
 }
 
+// handleQueue lists pending requests: GET /api/request/queue[?board=]
 func handleQueue(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(requestsListPending())
-}
-
-func handleActive(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(requestsListActive())
-}
-
-func handleApprove(w http.ResponseWriter, r *http.Request) {
-	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
-	it, ok := requestFind(id)
-	if !ok || it.Status != "pending" {
-		http.Error(w, "unknown or not pending", http.StatusNotFound)
-		return
-	}
-	reqMu.Lock()
-	it.Status = "approved"
-	reqActive[id] = it
-	reqMu.Unlock()
-
-	ws.Broadcast(ws.WSMsg{
-		Type: "REQUEST_ADD",
-		Data: map[string]any{
-			"id":           it.ID,
-			"board":        it.Board,
-			"masked_phone": it.MaskedPhone,
-			"note":         it.Note,
-		},
-	})
-	w.Write([]byte("ok"))
-}
-
-func handleReject(w http.ResponseWriter, r *http.Request) {
-	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
-	it, ok := requestFind(id)
-	if !ok || it.Status != "pending" {
-		http.Error(w, "unknown or not pending", http.StatusNotFound)
+	board, ok := queryBoard(w, r)
+	if !ok {
 		return
 	}
-	reqMu.Lock()
-	it.Status = "rejected"
-	reqMu.Unlock()
-	w.Write([]byte("ok"))
+	api.OK(w, requestsListPending(board))
 }
 
-func handleComplete(w http.ResponseWriter, r *http.Request) {
-	id, _ := strconv.Atoi(r.URL.Query().Get("id"))
-	reqMu.Lock()
-	_, ok := reqActive[id]
-	if ok {
-		delete(reqActive, id)
-	}
-	reqMu.Unlock()
-
+// handleActive lists approved and in-call requests: GET /api/request/active[?board=]
+func handleActive(w http.ResponseWriter, r *http.Request) {
+	board, ok := queryBoard(w, r)
 	if !ok {
-		http.Error(w, "unknown active request id", http.StatusNotFound)
 		return
 	}
-	ws.Broadcast(ws.WSMsg{Type: "REQUEST_REMOVE", Data: map[string]any{"id": id}})
-	w.Write([]byte("ok"))
+	api.OK(w, requestsListActive(board))
 }
 
 // ─────────────────────────────────────────────────────────────────────────────
 // State-persistence helpers: exported so internal/state.go can call them.
 // ─────────────────────────────────────────────────────────────────────────────
 
+// PendingCount returns the number of requests awaiting approval.
+func PendingCount() int { return len(requestsListPending("")) }
+
+// ActiveCount returns the number of approved or in-call requests.
+func ActiveCount() int {
+	reqMu.Lock()
+	defer reqMu.Unlock()
+	return len(reqActive)
+}
+
+// Snapshots returned by the Get* helpers below include the encrypted phone
+// number; use them for persistence, not for API responses.
+
+// GetHeldRequests returns a copy of all requests awaiting payment.
+func GetHeldRequests() []*RequestItem {
+	reqMu.Lock()
+	defer reqMu.Unlock()
+	out := make([]*RequestItem, len(reqHeld))
+	copy(out, reqHeld)
+	return out
+}
+
 // GetPendingRequests returns a copy of all pending requests.
 func GetPendingRequests() []*RequestItem {
 	reqMu.Lock()
@@ -206,7 +283,7 @@ func GetPendingRequests() []*RequestItem {
 	return out
 }
 
-// GetActiveRequests returns a copy of all approved/active requests.
+// GetActiveRequests returns a copy of all approved/in-call requests.
 func GetActiveRequests() []*RequestItem {
 	reqMu.Lock()
 	defer reqMu.Unlock()
@@ -214,6 +291,16 @@ func GetActiveRequests() []*RequestItem {
 	for _, it := range reqActive {
 		out = append(out, it)
 	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// GetCallLog returns a copy of all finished requests, oldest first.
+func GetCallLog() []*RequestItem {
+	reqMu.Lock()
+	defer reqMu.Unlock()
+	out := make([]*RequestItem, len(reqLog))
+	copy(out, reqLog)
 	return out
 }
 
@@ -225,13 +312,72 @@ func GetNextID() int {
 }
 
 // SetState replaces in-memory request state (used by state.LoadState).
-func SetState(pending []*RequestItem, active []*RequestItem, seq int) {
+// Items are re-filed by status. Snapshots from before the call log kept
+// handled items in the pending list; approved ones missing from the active
+// list had been completed, so they are logged as such.
+func SetState(held, pending, active, log []*RequestItem, seq int) {
 	reqMu.Lock()
 	defer reqMu.Unlock()
-	reqQueue = append([]*RequestItem{}, pending...)
+	reqHeld = []*RequestItem{}
+	reqQueue = []*RequestItem{}
 	reqActive = make(map[int]*RequestItem, len(active))
+	reqLog = []*RequestItem{}
+	isActive := make(map[int]bool, len(active))
+	for _, list := range [][]*RequestItem{held, pending, active, log} {
+		for _, it := range list {
+			migratePhone(it)
+			if b, ok := catalog.GetBoard(it.Board); ok {
+				it.Board = b.ID // free-text board names from before boards were catalogued
+			}
+		}
+	}
 	for _, it := range active {
-		reqActive[it.ID] = it
+		isActive[it.ID] = true
+	}
+	for _, it := range log {
+		fileLocked(it)
+	}
+	for _, it := range pending {
+		if it.Status == StatusApproved && !isActive[it.ID] {
+			it.Status = StatusCompleted
+			it.OutcomeNote = "completed before the call log existed"
+		}
+		if it.Status == StatusApproved {
+			continue // the active list has the current copy
+		}
+		fileLocked(it)
+	}
+	for _, it := range active {
+		fileLocked(it)
+	}
+	for _, it := range held {
+		fileLocked(it)
 	}
 	reqSeq = seq
 }
+
+// migratePhone encrypts a plaintext number from an older snapshot, in
+// E.164 form when it parses in the default region. If that can't be done
+// the number is dropped rather than kept in the clear.
+func migratePhone(it *RequestItem) {
+	if it.LegacyPhone == "" {
+		return
+	}
+	if it.PhoneEnc == "" {
+		phone := it.LegacyPhone
+		if num, err := parsePhone(phone, DefaultRegion()); err == nil {
+			phone = num.E164()
+			it.PhoneRegion = num.Region.Code
+		}
+		enc, err := encryptPhone(phone)
+		if err == nil {
+			it.PhoneHash, err = phoneHash(phone)
+		}
+		if err != nil {
+			logger.Error("cannot encrypt saved phone number; dropping it", "id", it.ID, "err", err)
+			enc, it.PhoneHash = "", ""
+		}
+		it.PhoneEnc = enc
+	}
+	it.LegacyPhone = ""
+}
@@ -0,0 +1,308 @@
+package requests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/catalog"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
+)
+
+// BlockEntry is a number or board that submissions are always refused for.
+// Numbers are kept only as their keyed hash.
+type BlockEntry struct {
+	ID        int    `json:"id"`
+	Kind      string `json:"kind"`  // "phone" or "board"
+	Value     string `json:"value"` // phone hash, or board ID
+	Label     string `json:"label"` // masked number or board name, for display
+	Reason    string `json:"reason,omitempty"`
+	AddedBy   string `json:"added_by"`
+	AddedUnix int64  `json:"added_unix"`
+}
+
+// DuplicateConfig controls how repeat submissions are caught: a request
+// matching one created within the window is merged into it (if it is still
+// open) or rejected. Matching on board allows one request per board per
+// window, so it is off by default.
+type DuplicateConfig struct {
+	WindowMinutes int    `json:"window_minutes"` // 0 disables
+	Action        string `json:"action"`         // "merge" or "reject"
+	MatchPhone    bool   `json:"match_phone"`
+	MatchBoard    bool   `json:"match_board"`
+}
+
+// DefaultDuplicates is used until an operator saves their own settings.
+func DefaultDuplicates() DuplicateConfig {
+	return DuplicateConfig{WindowMinutes: 60, Action: "merge", MatchPhone: true}
+}
+
+// screenMu guards the blocklist and duplicate settings. It is taken after
+// reqMu when both are needed.
+var (
+	screenMu  sync.Mutex
+	blocklist = []BlockEntry{}
+	blockSeq  = 0
+	dupCfg    = DefaultDuplicates()
+)
+
+var mScreened = metrics.NewCounter("overlay_request_screened_total", "Submissions refused or merged on arrival, by reason.", "result")
+
+// GetBlocklist returns a copy of the blocklist.
+func GetBlocklist() []BlockEntry {
+	screenMu.Lock()
+	defer screenMu.Unlock()
+	return append([]BlockEntry{}, blocklist...)
+}
+
+// SetBlocklist replaces the blocklist (used by state.LoadState).
+func SetBlocklist(list []BlockEntry) {
+	screenMu.Lock()
+	defer screenMu.Unlock()
+	blocklist = append([]BlockEntry{}, list...)
+	blockSeq = 0
+	for _, e := range blocklist {
+		blockSeq = max(blockSeq, e.ID)
+	}
+}
+
+// GetDuplicates returns the duplicate-detection settings.
+func GetDuplicates() DuplicateConfig {
+	screenMu.Lock()
+	defer screenMu.Unlock()
+	return dupCfg
+}
+
+// SetDuplicates replaces the duplicate-detection settings (used by state.LoadState).
+func SetDuplicates(c DuplicateConfig) {
+	screenMu.Lock()
+	defer screenMu.Unlock()
+	dupCfg = c
+}
+
+func boardKey(board string) string { return strings.ToLower(strings.TrimSpace(board)) }
+
+func blockedBy(it *RequestItem) (BlockEntry, bool) {
+	screenMu.Lock()
+	defer screenMu.Unlock()
+	for _, e := range blocklist {
+		switch {
+		case e.Kind == "phone" && it.PhoneHash != "" && e.Value == it.PhoneHash:
+			return e, true
+		case e.Kind == "board" && it.Board != "" && e.Value == boardKey(it.Board):
+			return e, true
+		}
+	}
+	return BlockEntry{}, false
+}
+
+// screenLocked checks a just-queued submission against the blocklist and
+// recent requests, rejecting or merging it with the reason recorded. It
+// returns the request it was merged into, if any, for the caller to
+// announce.
+func screenLocked(it *RequestItem, now time.Time) *RequestItem {
+	if e, ok := blockedBy(it); ok {
+		reason := "blocked " + e.Kind
+		if e.Reason != "" {
+			reason += ": " + e.Reason
+		}
+		it.AutoReason = reason
+		transitionLocked(it, StatusRejected, "system", reason)
+		mScreened.Inc("blocked")
+		return nil
+	}
+	cfg := GetDuplicates()
+	if cfg.WindowMinutes <= 0 {
+		return nil
+	}
+	orig := findDuplicateLocked(it, cfg, now.Add(-time.Duration(cfg.WindowMinutes)*time.Minute).Unix())
+	if orig == nil {
+		return nil
+	}
+	it.DuplicateOf = orig.ID
+	it.AutoReason = fmt.Sprintf("duplicate of #%d", orig.ID)
+	if cfg.Action == "merge" && !Terminal(orig.Status) {
+		orig.Duplicates++
+		transitionLocked(it, StatusMerged, "system", it.AutoReason)
+		mScreened.Inc("merged")
+		return orig
+	}
+	transitionLocked(it, StatusRejected, "system", it.AutoReason)
+	mScreened.Inc("duplicate")
+	return nil
+}
+
+// findDuplicateLocked returns the request it repeats, preferring ones still
+// open. Requests that were themselves duplicates don't count, so repeats
+// always point at the original.
+func findDuplicateLocked(it *RequestItem, cfg DuplicateConfig, cutoff int64) *RequestItem {
+	matches := func(o *RequestItem) bool {
+		if o == it || o.DuplicateOf != 0 || o.CreatedUnix < cutoff {
+			return false
+		}
+		return cfg.MatchPhone && it.PhoneHash != "" && o.PhoneHash == it.PhoneHash ||
+			cfg.MatchBoard && it.Board != "" && boardKey(o.Board) == boardKey(it.Board)
+	}
+	for _, list := range [][]*RequestItem{reqHeld, reqQueue} {
+		for _, o := range list {
+			if matches(o) {
+				return o
+			}
+		}
+	}
+	for _, o := range reqActive {
+		if matches(o) {
+			return o
+		}
+	}
+	for i := len(reqLog) - 1; i >= 0; i-- {
+		if matches(reqLog[i]) {
+			return reqLog[i]
+		}
+	}
+	return nil
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// HTTP
+// ─────────────────────────────────────────────────────────────────────────────
+
+func handleGetBlocklist(w http.ResponseWriter, r *http.Request) {
+	api.OK(w, GetBlocklist())
+}
+
+// handleBlock adds an entry: POST /api/request/blocklist with exactly one of
+// phone (plus optional region), board, or request_id (blocks that
+// request's number).
+func handleBlock(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Phone     string `json:"phone"`
+		Region    string `json:"region"`
+		Board     string `json:"board"`
+		RequestID int    `json:"request_id"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		api.Error(w, http.StatusBadRequest, api.CodeBadRequest, "invalid JSON payload")
+		return
+	}
+	given := 0
+	for _, set := range []bool{in.Phone != "", strings.TrimSpace(in.Board) != "", in.RequestID != 0} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		api.Invalid(w, "phone", "give exactly one of phone, board or request_id")
+		return
+	}
+	e := BlockEntry{Reason: strings.TrimSpace(in.Reason), AddedBy: operatorFrom(r), AddedUnix: time.Now().Unix()}
+	switch {
+	case in.Board != "":
+		b, ok := catalog.GetBoard(in.Board)
+		if !ok {
+			api.Invalid(w, "board", "unknown board "+in.Board)
+			return
+		}
+		e.Kind, e.Value, e.Label = "board", boardKey(b.ID), b.Name
+	case in.Phone != "":
+		region := DefaultRegion()
+		if in.Region != "" {
+			rg, ok := lookupRegion(in.Region)
+			if !ok {
+				api.Invalid(w, "region", "unsupported region "+in.Region)
+				return
+			}
+			region = rg
+		}
+		num, err := parsePhone(in.Phone, region)
+		if err != nil {
+			writePhoneErr(w, err)
+			return
+		}
+		hash, err := phoneHash(num.E164())
+		if err != nil {
+			api.Error(w, http.StatusInternalServerError, api.CodeInternal, err.Error())
+			return
+		}
+		e.Kind, e.Value, e.Label = "phone", hash, num.Masked()
+	default:
+		reqMu.Lock()
+		it, found := findLocked(in.RequestID)
+		var hash, label string
+		if found {
+			hash, label = it.PhoneHash, it.MaskedPhone
+		}
+		reqMu.Unlock()
+		switch {
+		case !found:
+			api.NotFound(w, "request")
+			return
+		case hash == "":
+			api.Conflict(w, fmt.Sprintf("request %d has no phone number on file", in.RequestID))
+			return
+		}
+		e.Kind, e.Value, e.Label = "phone", hash, label
+	}
+
+	screenMu.Lock()
+	for _, x := range blocklist {
+		if x.Kind == e.Kind && x.Value == e.Value {
+			screenMu.Unlock()
+			api.Conflict(w, fmt.Sprintf("already blocked (entry %d)", x.ID))
+			return
+		}
+	}
+	blockSeq++
+	e.ID = blockSeq
+	blocklist = append(blocklist, e)
+	screenMu.Unlock()
+	logger.InfoContext(r.Context(), "blocklist entry added", "id", e.ID, "kind", e.Kind, "label", e.Label, "operator", e.AddedBy)
+	api.Created(w, e)
+}
+
+// handleUnblock removes an entry: POST /api/request/blocklist/remove?id=
+func handleUnblock(w http.ResponseWriter, r *http.Request) {
+	id, ok := api.QueryInt(w, r, "id")
+	if !ok {
+		return
+	}
+	screenMu.Lock()
+	defer screenMu.Unlock()
+	for i, e := range blocklist {
+		if e.ID == id {
+			blocklist = append(blocklist[:i], blocklist[i+1:]...)
+			logger.InfoContext(r.Context(), "blocklist entry removed", "id", id, "kind", e.Kind, "operator", operatorFrom(r))
+			api.OK(w, e)
+			return
+		}
+	}
+	api.NotFound(w, "blocklist entry")
+}
+
+func handleGetDuplicates(w http.ResponseWriter, r *http.Request) {
+	api.OK(w, GetDuplicates())
+}
+
+func handlePutDuplicates(w http.ResponseWriter, r *http.Request) {
+	var c DuplicateConfig
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		api.Error(w, http.StatusBadRequest, api.CodeBadRequest, "invalid JSON payload")
+		return
+	}
+	switch {
+	case c.WindowMinutes < 0:
+		api.Invalid(w, "window_minutes", "window_minutes must not be negative")
+		return
+	case c.Action != "merge" && c.Action != "reject":
+		api.Invalid(w, "action", "action must be merge or reject")
+		return
+	}
+	SetDuplicates(c)
+	logger.InfoContext(r.Context(), "duplicate detection updated", "window_minutes", c.WindowMinutes, "action", c.Action)
+	api.OK(w, GetDuplicates())
+}
@@ -0,0 +1,170 @@
+package requests
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/catalog"
+	"github.com/go-chi/chi/v5"
+)
+
+// Viewers follow their request with the tracking token handed back on
+// submit. The public status endpoint answers with nothing that identifies
+// the request or the caller: just where it stands and roughly how long
+// until the call.
+
+// PublicStatus is what GET /api/request/status/{token} returns.
+type PublicStatus struct {
+	Status     string `json:"status"`
+	Position   int    `json:"position,omitempty"`    // place in its board's queue; 1 is next
+	ETASeconds *int64 `json:"eta_seconds,omitempty"` // estimated wait, while pending or approved
+}
+
+// SubmitReceipt is the response to a submission.
+type SubmitReceipt struct {
+	Token      string `json:"token"`
+	StatusPage string `json:"status_page"` // embeddable page following this request
+	PublicStatus
+	PaymentRef string `json:"payment_ref,omitempty"` // on paid boards: quote in the donation message
+	PriceCents int64  `json:"price_cents,omitempty"`
+}
+
+const (
+	// defaultCallSeconds is assumed until some calls have finished.
+	defaultCallSeconds = 180
+	// callSample is how many recent calls the average duration covers.
+	callSample = 20
+)
+
+func newTrackingToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand doesn't fail on supported platforms
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func findByTokenLocked(token string) *RequestItem {
+	if token == "" {
+		return nil
+	}
+	for _, list := range [][]*RequestItem{reqHeld, reqQueue, reqLog} {
+		for _, it := range list {
+			if it.Token == token {
+				return it
+			}
+		}
+	}
+	for _, it := range reqActive {
+		if it.Token == token {
+			return it
+		}
+	}
+	return nil
+}
+
+// callSeconds is how long it was on the call, if it got that far.
+func callSeconds(it *RequestItem) (int64, bool) {
+	var start int64
+	for _, t := range it.History {
+		switch {
+		case t.To == StatusInCall:
+			start = t.Unix
+		case t.From == StatusInCall && start != 0:
+			return t.Unix - start, true
+		}
+	}
+	return 0, false
+}
+
+// avgCallSecondsLocked is the mean duration of the most recent calls.
+func avgCallSecondsLocked() int64 {
+	var sum, n int64
+	for i := len(reqLog) - 1; i >= 0 && n < callSample; i-- {
+		if d, ok := callSeconds(reqLog[i]); ok {
+			sum += d
+			n++
+		}
+	}
+	if n == 0 {
+		return defaultCallSeconds
+	}
+	return sum / n
+}
+
+// statusLocked reports where it stands. A request merged into an earlier
+// one follows the original, since that is the call that will happen.
+func statusLocked(it *RequestItem) PublicStatus {
+	if it.Status == StatusMerged && it.DuplicateOf != 0 {
+		if orig, ok := findLocked(it.DuplicateOf); ok {
+			it = orig
+		}
+	}
+	st := PublicStatus{Status: it.Status}
+	if it.Status != StatusPending && it.Status != StatusApproved {
+		return st
+	}
+	// Calls on a board run MaxActive at a time (one if unlimited).
+	slots := int64(1)
+	if b, ok := catalog.GetBoard(it.Board); ok && b.MaxActive > 0 {
+		slots = int64(b.MaxActive)
+	}
+	// Everything already approved is ahead of a pending request; an
+	// approved one waits only for calls in progress and for requests
+	// approved before it.
+	var ahead int64
+	for _, o := range reqActive {
+		if o.Board != it.Board || o == it {
+			continue
+		}
+		if it.Status == StatusPending || o.Status == StatusInCall || approvedBefore(o, it) {
+			ahead++
+		}
+	}
+	if it.Status == StatusPending {
+		st.Position = 1
+		for _, o := range reqQueue {
+			if o == it {
+				break
+			}
+			if o.Board == it.Board {
+				st.Position++
+			}
+		}
+		ahead += int64(st.Position - 1)
+	}
+	eta := ahead * avgCallSecondsLocked() / slots
+	st.ETASeconds = &eta
+	return st
+}
+
+// approvedBefore reports whether approved request a was approved ahead of
+// b, breaking ties (and requests saved before history was kept) by ID.
+func approvedBefore(a, b *RequestItem) bool {
+	if a.Status != StatusApproved {
+		return false
+	}
+	at, bt := enteredUnix(a, StatusApproved), enteredUnix(b, StatusApproved)
+	if at != bt {
+		return at < bt
+	}
+	return a.ID < b.ID
+}
+
+// handleStatus is the public lookup: GET /api/request/status/{token}
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	reqMu.Lock()
+	it := findByTokenLocked(chi.URLParam(r, "token"))
+	var st PublicStatus
+	if it != nil {
+		st = statusLocked(it)
+	}
+	reqMu.Unlock()
+	if it == nil {
+		api.NotFound(w, "request")
+		return
+	}
+	api.OK(w, st)
+}
@@ -2,11 +2,13 @@ package state
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/logging"
 	"github.com/dtorres47/stream-overlay/internal/quests"
 	"github.com/dtorres47/stream-overlay/internal/requests"
 	"github.com/dtorres47/stream-overlay/internal/tts"
@@ -15,41 +17,113 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
+var logger = logging.For("state")
+
 type PersistState struct {
-	ActiveQuests    []quests.QuestState     `json:"active_quests"`
-	RequestsPending []*requests.RequestItem `json:"requests_pending"`
-	RequestsActive  []*requests.RequestItem `json:"requests_active"`
-	TTSQueue        []*tts.TTSItem          `json:"tts_queue"`
-	ReqSeq          int                     `json:"req_seq"`
-	TTSSeq          int                     `json:"tts_seq"`
-	SavedAtUnix     int64                   `json:"saved_at_unix"`
+	ActiveQuests    []quests.QuestState       `json:"active_quests"`
+	QuestFunding    []quests.Funding          `json:"quest_funding,omitempty"`
+	QuestSessions   []quests.Session          `json:"quest_sessions,omitempty"`
+	QuestTimers     *quests.TimerState        `json:"quest_timers,omitempty"`
+	RequestsHeld    []*requests.RequestItem   `json:"requests_held,omitempty"`
+	RequestsPending []*requests.RequestItem   `json:"requests_pending"`
+	RequestsActive  []*requests.RequestItem   `json:"requests_active"`
+	RequestsLog     []*requests.RequestItem   `json:"requests_log"`
+	RequestBlocks   []requests.BlockEntry     `json:"request_blocklist,omitempty"`
+	RequestDupes    *requests.DuplicateConfig `json:"request_duplicates,omitempty"`
+	TTSQueue        []*tts.TTSItem            `json:"tts_queue"`
+	TTSHistory      []*tts.TTSItem            `json:"tts_history"`
+	ReqSeq          int                       `json:"req_seq"`
+	TTSSeq          int                       `json:"tts_seq"`
+	TTSModeration   *tts.ModerationConfig     `json:"tts_moderation,omitempty"`
+	TTSAutoApprove  *tts.AutoApproveConfig    `json:"tts_auto_approve,omitempty"`
+	TTSQueueConfig  *tts.QueueConfig          `json:"tts_queue_config,omitempty"`
+	TTSVoices       []tts.Voice               `json:"tts_voices,omitempty"`
+	TTSNormalize    *tts.NormalizeConfig      `json:"tts_normalize,omitempty"`
+	TTSLimits       *tts.LimitsConfig         `json:"tts_limits,omitempty"`
+	SavedAtUnix     int64                     `json:"saved_at_unix"`
+}
+
+// SaveStatus tracks the outcome of state saves for health reporting.
+type SaveStatus struct {
+	LastSuccessUnix int64  `json:"last_success_unix,omitempty"`
+	LastAttemptUnix int64  `json:"last_attempt_unix,omitempty"`
+	LastError       string `json:"last_error,omitempty"`
+}
+
+var (
+	saveMu     sync.Mutex
+	saveStatus SaveStatus
+)
+
+// Status returns the outcome of the most recent SaveState call.
+func Status() SaveStatus {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+	return saveStatus
 }
 
-func SaveState() {
+func recordSave(err error) {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+	saveStatus.LastAttemptUnix = time.Now().Unix()
+	if err != nil {
+		saveStatus.LastError = err.Error()
+		return
+	}
+	saveStatus.LastError = ""
+	saveStatus.LastSuccessUnix = saveStatus.LastAttemptUnix
+}
+
+func SaveState() error {
 	ps := PersistState{SavedAtUnix: time.Now().Unix()}
 
 	// snapshot quests
 	ps.ActiveQuests = quests.ListActiveQuests()
+	ps.QuestFunding = quests.ListFunding()
+	ps.QuestSessions = quests.ListSessions()
+	timers := quests.GetTimers()
+	ps.QuestTimers = &timers
 
 	// snapshot requests
+	ps.RequestsHeld = requests.GetHeldRequests()
 	ps.RequestsPending = requests.GetPendingRequests()
 	ps.RequestsActive = requests.GetActiveRequests()
+	ps.RequestsLog = requests.GetCallLog()
 	ps.ReqSeq = requests.GetNextID()
+	ps.RequestBlocks = requests.GetBlocklist()
+	dup := requests.GetDuplicates()
+	ps.RequestDupes = &dup
 
 	// snapshot TTS
 	ps.TTSQueue = tts.GetQueue()
+	ps.TTSHistory = tts.GetHistory()
 	ps.TTSSeq = tts.GetNextID()
+	mod := tts.GetModeration()
+	ps.TTSModeration = &mod
+	rules := tts.GetAutoApprove()
+	ps.TTSAutoApprove = &rules
+	qc := tts.GetQueueConfig()
+	ps.TTSQueueConfig = &qc
+	ps.TTSVoices = tts.GetVoices()
+	norm := tts.GetNormalize()
+	ps.TTSNormalize = &norm
+	lim := tts.GetLimits()
+	ps.TTSLimits = &lim
 
 	b, err := json.MarshalIndent(ps, "", "  ")
 	if err != nil {
-		log.Println("state marshal error:", err)
-		return
+		logger.Error("state marshal failed", "err", err)
+		recordSave(err)
+		return err
 	}
 	if err := os.WriteFile("state.json", b, 0644); err != nil {
-		log.Println("state write error:", err)
-		return
+		logger.Error("state write failed", "err", err)
+		recordSave(err)
+		return err
 	}
-	log.Printf("state saved")
+	recordSave(nil)
+	logger.Info("state saved", "quests", len(ps.ActiveQuests), "tts", len(ps.TTSQueue))
+	return nil
 }
 
 func LoadState() {
@@ -59,42 +133,77 @@ func LoadState() {
 	}
 	var ps PersistState
 	if err := json.Unmarshal(b, &ps); err != nil {
-		log.Println("state parse error:", err)
+		logger.Error("state parse failed", "err", err)
 		return
 	}
 
 	// restore quests
 	quests.SetState(ps.ActiveQuests)
+	quests.SetFunding(ps.QuestFunding)
+	quests.SetSessions(ps.QuestSessions)
+	if ps.QuestTimers != nil {
+		quests.SetTimers(*ps.QuestTimers)
+	}
 
 	// restore requests
-	requests.SetState(ps.RequestsPending, ps.RequestsActive, ps.ReqSeq)
+	requests.SetState(ps.RequestsHeld, ps.RequestsPending, ps.RequestsActive, ps.RequestsLog, ps.ReqSeq)
+	if ps.RequestBlocks != nil {
+		requests.SetBlocklist(ps.RequestBlocks)
+	}
+	if ps.RequestDupes != nil {
+		requests.SetDuplicates(*ps.RequestDupes)
+	}
 
 	// restore TTS
-	tts.SetState(ps.TTSQueue, ps.TTSSeq)
+	// config first: compaction on restore trims history to its limit
+	if ps.TTSQueueConfig != nil {
+		tts.SetQueueConfig(*ps.TTSQueueConfig)
+	}
+	tts.SetState(ps.TTSQueue, ps.TTSHistory, ps.TTSSeq)
+	if ps.TTSVoices != nil {
+		tts.SetVoices(ps.TTSVoices)
+	}
+	if ps.TTSLimits != nil {
+		tts.SetLimits(*ps.TTSLimits)
+	}
+	if ps.TTSNormalize != nil {
+		if err := tts.SetNormalize(*ps.TTSNormalize); err != nil {
+			logger.Error("saved tts normalization rejected; using defaults", "err", err)
+		}
+	}
+	if ps.TTSModeration != nil {
+		tts.SetModeration(*ps.TTSModeration)
+	}
+	if ps.TTSAutoApprove != nil {
+		tts.SetAutoApprove(*ps.TTSAutoApprove)
+	}
 
-	log.Printf("state loaded")
+	logger.Info("state loaded", "saved_at_unix", ps.SavedAtUnix)
 }
 
 func RegisterRoutes(r chi.Router) {
 	r.Post("/api/state/save", func(w http.ResponseWriter, r *http.Request) {
-		SaveState()
-		w.Write([]byte("ok"))
+		if err := SaveState(); err != nil {
+			api.Error(w, http.StatusInternalServerError, api.CodeInternal, "state save failed: "+err.Error())
+			return
+		}
+		api.OK(w, Status())
 	})
 	r.Post("/api/state/rehydrate", func(w http.ResponseWriter, r *http.Request) {
+		qs := quests.ListActiveQuests()
+		active := requests.GetActiveRequests()
 		// rebroadcast quests
-		for _, qs := range quests.ListActiveQuests() {
+		for _, qs := range qs {
 			ws.Broadcast(ws.WSMsg{Type: "QUEST_UPSERT", Data: qs})
 		}
+		for _, f := range quests.ListFunding() {
+			ws.Broadcast(ws.WSMsg{Type: "QUEST_FUNDING", Data: f})
+		}
 		// rebroadcast requests
-		for _, it := range requests.GetActiveRequests() {
-			ws.Broadcast(ws.WSMsg{Type: "REQUEST_ADD", Data: map[string]any{
-				"id":           it.ID,
-				"board":        it.Board,
-				"masked_phone": it.MaskedPhone,
-				"note":         it.Note,
-			}})
+		for _, it := range active {
+			ws.Broadcast(ws.WSMsg{Type: "REQUEST_ADD", Data: requests.OverlayView(*it)})
 		}
 		// rebroadcast TTS if desired (omitted for brevity)
-		w.Write([]byte("ok"))
+		api.OK(w, map[string]int{"quests": len(qs), "requests": len(active)})
 	})
 }
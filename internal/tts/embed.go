@@ -0,0 +1,6 @@
+package tts
+
+import _ "embed"
+
+//go:embed voices.json
+var embeddedVoices []byte
@@ -0,0 +1,168 @@
+package tts
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+)
+
+// CharBudget allows MaxChars of text to donations of at least MinAmountCents.
+type CharBudget struct {
+	MinAmountCents int64 `json:"min_amount_cents"`
+	MaxChars       int   `json:"max_chars"`
+}
+
+// LimitsConfig scales how much a donor may say with what they paid, and how
+// often. ModerationConfig.MaxLength still applies as an overall cap.
+type LimitsConfig struct {
+	Budgets         []CharBudget `json:"budgets"`          // ascending by amount; the highest reached applies
+	CooldownSeconds int          `json:"cooldown_seconds"` // per donor; 0 disables
+}
+
+// DefaultLimits is used until an operator saves their own settings.
+func DefaultLimits() LimitsConfig {
+	return LimitsConfig{
+		Budgets: []CharBudget{
+			{MinAmountCents: 0, MaxChars: 100},
+			{MinAmountCents: 500, MaxChars: 200},
+			{MinAmountCents: 2000, MaxChars: 300},
+		},
+		CooldownSeconds: 60,
+	}
+}
+
+var (
+	limitsMu  sync.Mutex
+	limitsCfg = DefaultLimits()
+
+	// lastSubmit is when each donor (lower-cased) last got an item queued.
+	lastSubmit = map[string]time.Time{}
+)
+
+// GetLimits returns the current budget and cooldown settings.
+func GetLimits() LimitsConfig {
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+	c := limitsCfg
+	c.Budgets = append([]CharBudget{}, limitsCfg.Budgets...)
+	return c
+}
+
+// SetLimits replaces the budget and cooldown settings (used by state.LoadState).
+func SetLimits(c LimitsConfig) {
+	if c.Budgets == nil {
+		c.Budgets = []CharBudget{}
+	}
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+	limitsCfg = c
+}
+
+// charLimit is the number of characters amountCents buys, capped by
+// maxLength; 0 means unlimited.
+func charLimit(amountCents int64, budgets []CharBudget, maxLength int) int {
+	limit := 0
+	for _, b := range budgets {
+		if amountCents >= b.MinAmountCents {
+			limit = b.MaxChars
+		}
+	}
+	if maxLength > 0 && (limit == 0 || maxLength < limit) {
+		limit = maxLength
+	}
+	return limit
+}
+
+func donorKey(donor string) string { return strings.ToLower(strings.TrimSpace(donor)) }
+
+// claimCooldown starts donor's cooldown and returns its length, or returns
+// the time still left and ok=false if one is already running. Anonymous
+// submissions aren't tracked.
+func claimCooldown(donor string, now time.Time) (wait time.Duration, ok bool) {
+	key := donorKey(donor)
+	cd := time.Duration(GetLimits().CooldownSeconds) * time.Second
+	if key == "" || cd <= 0 {
+		return 0, true
+	}
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+	if left := lastSubmit[key].Add(cd).Sub(now); left > 0 {
+		return left, false
+	}
+	if len(lastSubmit) > 1000 {
+		for k, t := range lastSubmit {
+			if now.Sub(t) >= cd {
+				delete(lastSubmit, k)
+			}
+		}
+	}
+	lastSubmit[key] = now
+	return cd, true
+}
+
+// truncateWords cuts s to at most max runes, backing up to the last word
+// boundary so speech never stops mid-word. A single word longer than max
+// is cut hard.
+func truncateWords(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	cut := max
+	if !unicode.IsSpace(r[max]) {
+		for cut > 0 && !unicode.IsSpace(r[cut-1]) {
+			cut--
+		}
+		if cut == 0 {
+			cut = max
+		}
+	}
+	return strings.TrimRightFunc(string(r[:cut]), func(c rune) bool {
+		return unicode.IsSpace(c) || unicode.IsPunct(c) && c != '?' && c != '!' && c != '.'
+	})
+}
+
+func validateLimits(c LimitsConfig) (field, msg string) {
+	for i, b := range c.Budgets {
+		f := fmt.Sprintf("budgets[%d]", i)
+		switch {
+		case b.MinAmountCents < 0 || b.MaxChars <= 0:
+			return f, "min_amount_cents must not be negative and max_chars must be positive"
+		case i > 0 && b.MinAmountCents <= c.Budgets[i-1].MinAmountCents:
+			return f + ".min_amount_cents", "budgets must be in ascending order of amount"
+		}
+	}
+	if c.CooldownSeconds < 0 {
+		return "cooldown_seconds", "cooldown_seconds must not be negative"
+	}
+	return "", ""
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// HTTP
+// ─────────────────────────────────────────────────────────────────────────────
+
+func handleGetLimits(w http.ResponseWriter, r *http.Request) {
+	api.OK(w, GetLimits())
+}
+
+func handlePutLimits(w http.ResponseWriter, r *http.Request) {
+	var c LimitsConfig
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		api.Error(w, http.StatusBadRequest, api.CodeBadRequest, "invalid JSON payload")
+		return
+	}
+	if field, msg := validateLimits(c); field != "" {
+		api.Invalid(w, field, msg)
+		return
+	}
+	SetLimits(c)
+	logger.InfoContext(r.Context(), "tts limits updated", "budgets", len(c.Budgets), "cooldown_seconds", c.CooldownSeconds)
+	api.OK(w, GetLimits())
+}
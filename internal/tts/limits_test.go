@@ -0,0 +1,45 @@
+package tts
+
+import "testing"
+
+func TestTruncateWords(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		max  int
+		want string
+	}{
+		{"short enough", "hello world", 20, "hello world"},
+		{"exact length", "hello world", 11, "hello world"},
+		{"cut on a space", "hello world again", 11, "hello world"},
+		{"back up to a word boundary", "hello world", 8, "hello"},
+		{"single long word cut hard", "supercalifragilistic", 5, "super"},
+		{"trailing comma dropped", "hi, there", 4, "hi"},
+		{"sentence punctuation kept", "wow! nice one", 6, "wow!"},
+		{"question mark kept", "why? because", 5, "why?"},
+		{"counts runes not bytes", "héllo wörld", 7, "héllo"},
+		{"emoji counted as one", "🎉🎉 party time", 8, "🎉🎉 party"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateWords(tt.in, tt.max); got != tt.want {
+				t.Errorf("truncateWords(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModerateTruncation(t *testing.T) {
+	cfg := DefaultModeration()
+	cfg.Blocklist = []string{"badword"}
+	cfg.MaxLength = 10
+
+	m := moderate("hello there friend", cfg)
+	if m.Text != "hello" || !m.Truncated || len(m.Flags) != 0 {
+		t.Errorf("clean long text: got %q truncated=%v flags=%v, want %q truncated with no flags", m.Text, m.Truncated, m.Flags, "hello")
+	}
+	m = moderate("hello there b4dw0rd", cfg)
+	if len(m.Flags) != 1 || m.Flags[0].Code != "blocklist" {
+		t.Errorf("blocklisted word past the cut: flags = %v, want one blocklist flag", m.Flags)
+	}
+}
@@ -0,0 +1,325 @@
+package tts
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
+)
+
+// ModerationConfig controls the filters every submission passes through
+// before it reaches the queue. It is persisted with the rest of the state.
+type ModerationConfig struct {
+	Blocklist   []string `json:"blocklist"`    // words matched after leetspeak normalisation
+	MaxLength   int      `json:"max_length"`   // runes; longer text is cut at a word boundary
+	MaxRepeat   int      `json:"max_repeat"`   // runs of one character longer than this are collapsed
+	MaxEmoji    int      `json:"max_emoji"`    // emoji beyond this are dropped; negative disables
+	StripURLs   bool     `json:"strip_urls"`   // remove links
+	StripPhones bool     `json:"strip_phones"` // remove phone numbers
+	RejectScore int      `json:"reject_score"` // auto-reject at or above this score; 0 disables
+}
+
+// Flag is one reason an item was modified or held for a closer look.
+type Flag struct {
+	Code   string `json:"code"` // blocklist, url, phone, repeated_chars, emoji
+	Detail string `json:"detail,omitempty"`
+}
+
+// Flag weights; anything on the blocklist is enough to auto-reject by default.
+var flagScore = map[string]int{
+	"blocklist":      10,
+	"phone":          3,
+	"url":            2,
+	"repeated_chars": 1,
+	"emoji":          1,
+}
+
+// DefaultModeration is used until an operator saves their own settings.
+func DefaultModeration() ModerationConfig {
+	return ModerationConfig{
+		Blocklist:   []string{},
+		MaxLength:   300,
+		MaxRepeat:   3,
+		MaxEmoji:    3,
+		StripURLs:   true,
+		StripPhones: true,
+		RejectScore: 10,
+	}
+}
+
+var (
+	modMu  sync.Mutex
+	modCfg = DefaultModeration()
+
+	mFlags = metrics.NewCounter("overlay_tts_flags_total", "Moderation flags raised on TTS submissions.", "code")
+)
+
+// GetModeration returns the current moderation settings.
+func GetModeration() ModerationConfig {
+	modMu.Lock()
+	defer modMu.Unlock()
+	c := modCfg
+	c.Blocklist = append([]string{}, modCfg.Blocklist...)
+	return c
+}
+
+// SetModeration replaces the moderation settings (used by state.LoadState).
+func SetModeration(c ModerationConfig) {
+	if c.Blocklist == nil {
+		c.Blocklist = []string{}
+	}
+	modMu.Lock()
+	defer modMu.Unlock()
+	modCfg = c
+}
+
+// moderation is the outcome of running text through the filters.
+type moderation struct {
+	Text      string
+	Flags     []Flag
+	Score     int
+	Truncated bool // Text was cut to MaxLength; not a flag, the donor just paid for less
+}
+
+func (m *moderation) flag(code, detail string) {
+	m.Flags = append(m.Flags, Flag{Code: code, Detail: detail})
+	m.Score += flagScore[code]
+}
+
+// Rejected reports whether the score crosses the auto-reject threshold.
+func (m moderation) rejected(cfg ModerationConfig) bool {
+	return cfg.RejectScore > 0 && m.Score >= cfg.RejectScore
+}
+
+var (
+	urlPattern   = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+|\b[a-z0-9-]+\.(?:com|net|org|io|gg|tv|ly|co|me|xyz|link)\b(?:/\S*)?`)
+	phoneInText  = regexp.MustCompile(`\+?\d[\d\s().-]{6,}\d`)
+	extraSpaces  = regexp.MustCompile(`\s{2,}`)
+	leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b", "@", "a", "$", "s", "!", "i", "|", "l", "+", "t")
+)
+
+// moderate runs every filter over text in a fixed order: strip, collapse,
+// limit, then scan what is left against the blocklist.
+func moderate(text string, cfg ModerationConfig) moderation {
+	m := moderation{Text: text}
+
+	if cfg.StripURLs {
+		if found := urlPattern.FindAllString(m.Text, -1); len(found) > 0 {
+			m.Text = urlPattern.ReplaceAllString(m.Text, " ")
+			m.flag("url", strings.Join(found, " "))
+		}
+	}
+	if cfg.StripPhones {
+		stripped := phoneInText.ReplaceAllStringFunc(m.Text, func(s string) string {
+			if countDigits(s) < 7 {
+				return s
+			}
+			return " "
+		})
+		if stripped != m.Text {
+			m.Text = stripped
+			m.flag("phone", "")
+		}
+	}
+	if cfg.MaxRepeat > 0 {
+		if collapsed, ok := collapseRuns(m.Text, cfg.MaxRepeat); ok {
+			m.Text = collapsed
+			m.flag("repeated_chars", "")
+		}
+	}
+	if cfg.MaxEmoji >= 0 {
+		if limited, n := limitEmoji(m.Text, cfg.MaxEmoji); n > cfg.MaxEmoji {
+			m.Text = limited
+			m.flag("emoji", strconv.Itoa(n))
+		}
+	}
+	m.Text = strings.TrimSpace(extraSpaces.ReplaceAllString(m.Text, " "))
+	// the blocklist sees the whole message, so a word past the cut still counts
+	for _, hit := range blocklistHits(m.Text, cfg.Blocklist) {
+		m.flag("blocklist", hit)
+	}
+	if cfg.MaxLength > 0 && len([]rune(m.Text)) > cfg.MaxLength {
+		m.Text = truncateWords(m.Text, cfg.MaxLength)
+		m.Truncated = true
+	}
+	return m
+}
+
+// normalizeLeet lower-cases s, maps leetspeak digits/symbols to letters and
+// drops everything that isn't a letter: "B4D-W0RD" → "badword".
+func normalizeLeet(s string) string {
+	s = leetReplacer.Replace(strings.ToLower(s))
+	return strings.Map(func(r rune) rune {
+		if !unicode.IsLetter(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// squeeze drops consecutive duplicate runes: "baaad" → "bad".
+func squeeze(s string) string {
+	var b strings.Builder
+	var last rune
+	for _, r := range s {
+		if r != last {
+			b.WriteRune(r)
+		}
+		last = r
+	}
+	return b.String()
+}
+
+// leetMatch reports whether a normalised token spells a normalised
+// blocklist word, allowing stretched letters ("baaad") but not dropped ones,
+// so "as" never matches "ass".
+func leetMatch(token, bad string) bool {
+	if token == bad {
+		return true
+	}
+	return len(token) > len(bad) && squeeze(token) == squeeze(bad)
+}
+
+func blocklistHits(text string, blocklist []string) []string {
+	if len(blocklist) == 0 {
+		return nil
+	}
+	words := strings.Fields(text)
+	tokens := make([]string, 0, len(words)+1)
+	for _, w := range words {
+		tokens = append(tokens, normalizeLeet(w))
+	}
+	// catch "b a d" by also joining runs of one-character words
+	var run strings.Builder
+	for _, w := range append(words, "  ") {
+		if n := normalizeLeet(w); len([]rune(n)) == 1 && len([]rune(w)) <= 2 {
+			run.WriteString(n)
+			continue
+		}
+		if run.Len() > 1 {
+			tokens = append(tokens, run.String())
+		}
+		run.Reset()
+	}
+
+	var hits []string
+	for _, bad := range blocklist {
+		n := normalizeLeet(bad)
+		if n == "" {
+			continue
+		}
+		for _, t := range tokens {
+			if leetMatch(t, n) {
+				hits = append(hits, bad)
+				break
+			}
+		}
+	}
+	return hits
+}
+
+// collapseRuns shortens any run of the same rune longer than max.
+func collapseRuns(s string, max int) (string, bool) {
+	var b strings.Builder
+	changed := false
+	var last rune
+	run := 0
+	for _, r := range s {
+		if r == last {
+			run++
+		} else {
+			last, run = r, 1
+		}
+		if run > max {
+			changed = true
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), changed
+}
+
+// limitEmoji keeps the first max emoji and drops the rest; it returns the
+// total number seen.
+func limitEmoji(s string, max int) (string, int) {
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		if isEmoji(r) {
+			n++
+			if n > max {
+				continue
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), n
+}
+
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF, // pictographs, emoticons, transport, symbols
+		r >= 0x2600 && r <= 0x27BF: // misc symbols & dingbats
+		return true
+	}
+	return false
+}
+
+// flagCodes renders the distinct flag codes as "blocklist,url".
+func flagCodes(flags []Flag) string {
+	seen := map[string]bool{}
+	var codes []string
+	for _, f := range flags {
+		if !seen[f.Code] {
+			seen[f.Code] = true
+			codes = append(codes, f.Code)
+		}
+	}
+	return strings.Join(codes, ",")
+}
+
+func countDigits(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+	return n
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// HTTP
+// ─────────────────────────────────────────────────────────────────────────────
+
+func handleGetModeration(w http.ResponseWriter, r *http.Request) {
+	api.OK(w, GetModeration())
+}
+
+func handlePutModeration(w http.ResponseWriter, r *http.Request) {
+	var c ModerationConfig
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		api.Error(w, http.StatusBadRequest, api.CodeBadRequest, "invalid JSON payload")
+		return
+	}
+	switch {
+	case c.MaxLength < 0:
+		api.Invalid(w, "max_length", "max_length must not be negative")
+		return
+	case c.MaxRepeat < 0:
+		api.Invalid(w, "max_repeat", "max_repeat must not be negative")
+		return
+	case c.RejectScore < 0:
+		api.Invalid(w, "reject_score", "reject_score must not be negative")
+		return
+	}
+	SetModeration(c)
+	logger.InfoContext(r.Context(), "tts moderation updated", "blocklist", len(c.Blocklist), "max_length", c.MaxLength)
+	api.OK(w, GetModeration())
+}
@@ -0,0 +1,376 @@
+package tts
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+)
+
+// Pronunciation rewrites a word (matched whole, case-insensitively) or a
+// regular expression into what the engine should say.
+type Pronunciation struct {
+	Match string `json:"match"`
+	Say   string `json:"say"`             // for regex rules $1 etc. expand to groups
+	Regex bool   `json:"regex,omitempty"` // treat Match as a regular expression
+}
+
+// NormalizeConfig controls how text is rewritten just before it is spoken.
+// The queue and panel keep the donor's text; only the speech changes.
+type NormalizeConfig struct {
+	Pronunciations []Pronunciation   `json:"pronunciations"`
+	Emotes         map[string]string `json:"emotes"` // emote code (case-sensitive) → short phrase
+	StripMarkdown  bool              `json:"strip_markdown"`
+	StripURLs      bool              `json:"strip_urls"`
+	ExpandCurrency bool              `json:"expand_currency"` // "$5.50" → "5 dollars and 50 cents"
+	ExpandNumbers  bool              `json:"expand_numbers"`  // "1,200" → "one thousand two hundred"
+}
+
+// DefaultNormalize is used until an operator saves their own settings.
+func DefaultNormalize() NormalizeConfig {
+	return NormalizeConfig{
+		Pronunciations: []Pronunciation{
+			{Match: "gg", Say: "good game"},
+			{Match: "brb", Say: "be right back"},
+			{Match: "tbh", Say: "to be honest"},
+		},
+		Emotes: map[string]string{
+			"PogChamp": "pog",
+			"Kappa":    "kappa",
+			"LUL":      "laughing",
+			"KEKW":     "kek",
+			"<3":       "heart",
+			":)":       "smiley",
+		},
+		StripMarkdown:  true,
+		StripURLs:      true,
+		ExpandCurrency: true,
+		ExpandNumbers:  true,
+	}
+}
+
+// rewrite is a compiled pronunciation or emote.
+type rewrite struct {
+	re      *regexp.Regexp
+	say     string
+	literal bool // say is inserted as-is rather than expanded
+	padded  bool // anchored on whitespace, which each match consumes
+}
+
+var (
+	normMu    sync.Mutex
+	normCfg   NormalizeConfig
+	normRules []rewrite
+)
+
+func init() {
+	if err := SetNormalize(DefaultNormalize()); err != nil {
+		logger.Error("default tts normalization does not compile", "err", err)
+	}
+}
+
+// GetNormalize returns the current normalization settings.
+func GetNormalize() NormalizeConfig {
+	normMu.Lock()
+	defer normMu.Unlock()
+	c := normCfg
+	c.Pronunciations = append([]Pronunciation{}, normCfg.Pronunciations...)
+	c.Emotes = make(map[string]string, len(normCfg.Emotes))
+	for k, v := range normCfg.Emotes {
+		c.Emotes[k] = v
+	}
+	return c
+}
+
+// SetNormalize compiles and installs c (used by state.LoadState). It
+// returns an error naming the first pronunciation that doesn't compile.
+func SetNormalize(c NormalizeConfig) error {
+	if c.Pronunciations == nil {
+		c.Pronunciations = []Pronunciation{}
+	}
+	if c.Emotes == nil {
+		c.Emotes = map[string]string{}
+	}
+	rules, err := compileRewrites(c)
+	if err != nil {
+		return err
+	}
+	normMu.Lock()
+	defer normMu.Unlock()
+	normCfg, normRules = c, rules
+	return nil
+}
+
+func compileRewrites(c NormalizeConfig) ([]rewrite, error) {
+	var out []rewrite
+	for i, p := range c.Pronunciations {
+		if p.Match == "" {
+			return nil, fmt.Errorf("pronunciations[%d]: match is required", i)
+		}
+		if p.Regex {
+			re, err := regexp.Compile(p.Match)
+			if err != nil {
+				return nil, fmt.Errorf("pronunciations[%d]: %v", i, err)
+			}
+			out = append(out, rewrite{re: re, say: p.Say})
+			continue
+		}
+		out = append(out, wordRewrite(p.Match, p.Say, true))
+	}
+	// longest first so "PogChamp" wins over a shorter code inside it
+	codes := make([]string, 0, len(c.Emotes))
+	for code := range c.Emotes {
+		if code != "" {
+			codes = append(codes, code)
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if len(codes[i]) != len(codes[j]) {
+			return len(codes[i]) > len(codes[j])
+		}
+		return codes[i] < codes[j]
+	})
+	for _, code := range codes {
+		out = append(out, wordRewrite(code, c.Emotes[code], false))
+	}
+	return out, nil
+}
+
+// wordRewrite matches w as a whole word. Edges that aren't word characters
+// (as in "<3") are anchored on whitespace instead of \b.
+func wordRewrite(w, say string, fold bool) rewrite {
+	rs := []rune(w)
+	p, pad := regexp.QuoteMeta(w), false
+	if isWordRune(rs[0]) {
+		p = `\b` + p
+	} else {
+		p, pad = `(?:^|\s)`+p, true
+	}
+	if isWordRune(rs[len(rs)-1]) {
+		p += `\b`
+	} else {
+		p, pad = p+`(?:\s|$)`, true
+	}
+	if fold {
+		p = `(?i)` + p
+	}
+	if pad {
+		say = " " + say + " "
+	}
+	return rewrite{re: regexp.MustCompile(p), say: say, literal: true, padded: pad}
+}
+
+func isWordRune(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+var (
+	mdLink     = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	mdEmphasis = []*regexp.Regexp{ // strongest first so "**x**" isn't read as "*" + "*x*" + "*"
+		regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`),
+		regexp.MustCompile(`__(\S(?:.*?\S)?)__`),
+		regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`),
+		regexp.MustCompile(`\*(\S(?:.*?\S)?)\*`),
+		regexp.MustCompile("`+([^`]+)`+"),
+	}
+	mdLineStart = regexp.MustCompile(`(?m)^\s*(?:#{1,6}|>+|[-*+]|\d+\.)\s+`)
+	mdSpoiler   = regexp.MustCompile(`\|\|(.+?)\|\|`)
+
+	currencyAmt = regexp.MustCompile(`([$€£])\s?(\d{1,3}(?:,\d{3})+|\d+)(?:\.(\d{1,2}))?\b`)
+	numberTok   = regexp.MustCompile(`\d{1,3}(?:,\d{3})+(?:\.\d+)?|\d+(?:\.\d+)?`)
+)
+
+// normalize rewrites text for speech in a fixed order: markup and links
+// out first, then the dictionary and emotes, then money and numbers.
+func normalize(text string, c NormalizeConfig, rules []rewrite) string {
+	if c.StripMarkdown {
+		text = mdLink.ReplaceAllString(text, "$1")
+		text = mdSpoiler.ReplaceAllString(text, "$1")
+		text = mdLineStart.ReplaceAllString(text, "")
+		for _, re := range mdEmphasis {
+			text = re.ReplaceAllString(text, "$1")
+		}
+	}
+	if c.StripURLs {
+		text = urlPattern.ReplaceAllString(text, " ")
+	}
+	for _, r := range rules {
+		if r.literal {
+			text = r.re.ReplaceAllLiteralString(text, r.say)
+			if r.padded {
+				// a match eats the space the next one is anchored on, so
+				// ":) :)" needs a second pass for every other code
+				text = r.re.ReplaceAllLiteralString(text, r.say)
+			}
+		} else {
+			text = r.re.ReplaceAllString(text, r.say)
+		}
+	}
+	if c.ExpandCurrency {
+		text = currencyAmt.ReplaceAllStringFunc(text, spellCurrency)
+	}
+	if c.ExpandNumbers {
+		text = spellNumbers(text)
+	}
+	return strings.TrimSpace(extraSpaces.ReplaceAllString(text, " "))
+}
+
+// Normalize rewrites text for speech using the current settings.
+func Normalize(text string) string {
+	normMu.Lock()
+	c, rules := normCfg, normRules
+	normMu.Unlock()
+	return normalize(text, c, rules)
+}
+
+// spellNumbers spells out the numbers in text that stand alone. Digits
+// touching letters are part of a word ("1st", "mp3", "4k") and are left
+// for the engine to read.
+func spellNumbers(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range numberTok.FindAllStringIndex(text, -1) {
+		before, _ := utf8.DecodeLastRuneInString(text[:m[0]])
+		after, _ := utf8.DecodeRuneInString(text[m[1]:])
+		if isWordRune(before) || isWordRune(after) {
+			continue
+		}
+		b.WriteString(text[last:m[0]])
+		b.WriteString(spellNumber(text[m[0]:m[1]]))
+		last = m[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+var currencyNames = map[string][4]string{
+	"$": {"dollar", "dollars", "cent", "cents"},
+	"€": {"euro", "euros", "cent", "cents"},
+	"£": {"pound", "pounds", "penny", "pence"},
+}
+
+func spellCurrency(s string) string {
+	m := currencyAmt.FindStringSubmatch(s)
+	names := currencyNames[m[1]]
+	whole := strings.ReplaceAll(m[2], ",", "")
+	out := whole + " " + plural(whole == "1", names[0], names[1])
+	if m[3] != "" {
+		frac := m[3]
+		if len(frac) == 1 {
+			frac += "0"
+		}
+		if n, _ := strconv.Atoi(frac); n > 0 {
+			out += " and " + strconv.Itoa(n) + " " + plural(n == 1, names[2], names[3])
+		}
+	}
+	return out
+}
+
+func plural(one bool, singular, many string) string {
+	if one {
+		return singular
+	}
+	return many
+}
+
+// spellNumber turns "1,234.5" into "one thousand two hundred thirty-four
+// point five". Numbers too large to say sensibly are read digit by digit.
+func spellNumber(s string) string {
+	s = strings.ReplaceAll(s, ",", "")
+	whole, frac, _ := strings.Cut(s, ".")
+	n, err := strconv.ParseInt(whole, 10, 64)
+	var out string
+	if err != nil || n >= 1e15 || (len(whole) > 1 && whole[0] == '0') {
+		out = spellDigits(whole)
+	} else {
+		out = numberWords(n)
+	}
+	if frac != "" {
+		out += " point " + spellDigits(frac)
+	}
+	return out
+}
+
+var (
+	ones = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	tens   = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+	scales = []struct {
+		n    int64
+		name string
+	}{{1e12, "trillion"}, {1e9, "billion"}, {1e6, "million"}, {1e3, "thousand"}}
+)
+
+func numberWords(n int64) string {
+	if n < 20 {
+		return ones[n]
+	}
+	if n < 100 {
+		if n%10 == 0 {
+			return tens[n/10]
+		}
+		return tens[n/10] + "-" + ones[n%10]
+	}
+	if n < 1000 {
+		if n%100 == 0 {
+			return ones[n/100] + " hundred"
+		}
+		return ones[n/100] + " hundred " + numberWords(n%100)
+	}
+	for _, sc := range scales {
+		if n >= sc.n {
+			out := numberWords(n/sc.n) + " " + sc.name
+			if n%sc.n != 0 {
+				out += " " + numberWords(n%sc.n)
+			}
+			return out
+		}
+	}
+	return "" // unreachable
+}
+
+func spellDigits(s string) string {
+	words := make([]string, 0, len(s))
+	for _, r := range s {
+		words = append(words, ones[r-'0'])
+	}
+	return strings.Join(words, " ")
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// HTTP
+// ─────────────────────────────────────────────────────────────────────────────
+
+func handleGetNormalize(w http.ResponseWriter, r *http.Request) {
+	api.OK(w, GetNormalize())
+}
+
+func handlePutNormalize(w http.ResponseWriter, r *http.Request) {
+	var c NormalizeConfig
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		api.Error(w, http.StatusBadRequest, api.CodeBadRequest, "invalid JSON payload")
+		return
+	}
+	if err := SetNormalize(c); err != nil {
+		api.Invalid(w, "pronunciations", err.Error())
+		return
+	}
+	logger.InfoContext(r.Context(), "tts normalization updated", "pronunciations", len(c.Pronunciations), "emotes", len(c.Emotes))
+	api.OK(w, GetNormalize())
+}
+
+// handlePreview shows what the engine would be given:
+// GET /api/tts/normalize/preview?text=...
+func handlePreview(w http.ResponseWriter, r *http.Request) {
+	text, ok := api.QueryString(w, r, "text")
+	if !ok {
+		return
+	}
+	api.OK(w, map[string]string{"text": text, "spoken": Normalize(text)})
+}
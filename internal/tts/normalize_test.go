@@ -0,0 +1,47 @@
+package tts
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	c := DefaultNormalize()
+	rules, err := compileRewrites(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain", "hello there", "hello there"},
+		{"pronunciation", "GG wp", "good game wp"},
+		{"pronunciation inside word", "eggs", "eggs"},
+		{"emote", "PogChamp", "pog"},
+		{"longest emote wins", "PogChamp Kappa", "pog kappa"},
+		{"emote run", "Kappa Kappa Kappa", "kappa kappa kappa"},
+		{"symbol emote run", ":) :) :)", "smiley smiley smiley"},
+		{"mixed symbol run", "<3 :) <3", "heart smiley heart"},
+		{"emote glued to word", "KappaKappa", "KappaKappa"},
+		{"emote is case-sensitive", "kappa", "kappa"},
+		{"markdown", "**big** and _small_ ~~gone~~", "big and _small_ gone"},
+		{"link", "see [this](https://x.io) now", "see this now"},
+		{"url", "go to https://x.io/a?b=1 now", "go to now"},
+		{"currency", "$5.50", "five dollars and fifty cents"},
+		{"one dollar", "$1", "one dollar"},
+		{"pounds", "£1,200", "one thousand two hundred pounds"},
+		{"zero cents dropped", "€3.00", "three euros"},
+		{"number", "1,234.5", "one thousand two hundred thirty-four point five"},
+		{"leading zero read as digits", "007", "zero zero seven"},
+		{"ordinal", "1st and 2nd place", "1st and 2nd place"},
+		{"digits inside a word", "send the mp3", "send the mp3"},
+		{"unit suffix", "4k stream at 60fps", "4k stream at 60fps"},
+		{"decimal touching letters", "3.5x speed", "3.5x speed"},
+		{"number before punctuation", "top 3!", "top three!"},
+		{"huge number read as digits", "1234567890123456", "one two three four five six seven eight nine zero one two three four five six"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalize(tt.in, c, rules); got != tt.want {
+				t.Errorf("normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
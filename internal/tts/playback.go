@@ -0,0 +1,226 @@
+package tts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
+	"github.com/dtorres47/stream-overlay/internal/ws"
+)
+
+var mPlayback = metrics.NewCounter("overlay_tts_playback_total", "How TTS playback ended, plus replays.", "result")
+
+// playGrace is added to a clip's length before giving up on TTS_DONE;
+// playWaitUnknown is used when the length isn't known (browser speech).
+const (
+	playGrace       = 15 * time.Second
+	playWaitUnknown = 2 * time.Minute
+)
+
+// finishPlaying moves a playing item to status ("spoken" or "skipped").
+// It reports false if the item isn't playing, so repeated TTS_DONEs from
+// several overlays only count once.
+func finishPlaying(id int, status string) (TTSItem, bool) {
+	ttsMu.Lock()
+	defer ttsMu.Unlock()
+	for _, it := range ttsQueue {
+		if it.ID == id && it.Status == "playing" {
+			it.Status = status
+			compactLocked()
+			return *it, true
+		}
+	}
+	return TTSItem{}, false
+}
+
+// watchPlayback marks id spoken if no overlay reports back in time, so a
+// closed overlay can't leave an item playing forever.
+func watchPlayback(id int, clip time.Duration) {
+	wait := playWaitUnknown
+	if clip > 0 {
+		wait = clip + playGrace
+	}
+	time.AfterFunc(wait, func() {
+		if _, ok := finishPlaying(id, "spoken"); ok {
+			mPlayback.Inc("timeout")
+			logger.Warn("tts playback not confirmed; marking spoken", "id", id)
+		}
+	})
+}
+
+// handleDone is the overlay's TTS_DONE: {"id": 12}. Other clients' are ignored.
+func handleDone(role string, data json.RawMessage) {
+	// only the overlay playing the clip knows it has finished
+	if role != "overlay" {
+		logger.Debug("ignoring TTS_DONE from a non-overlay client", "role", role)
+		return
+	}
+	var d struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(data, &d); err != nil || d.ID == 0 {
+		logger.Debug("malformed TTS_DONE", "role", role)
+		return
+	}
+	if _, ok := finishPlaying(d.ID, "spoken"); ok {
+		mPlayback.Inc("spoken")
+		logger.Info("tts playback finished", "id", d.ID, "role", role)
+	}
+}
+
+// oldestPlaying returns the ID of the item that started playing first.
+func oldestPlaying() (int, bool) {
+	ttsMu.Lock()
+	defer ttsMu.Unlock()
+	for _, it := range ttsQueue {
+		if it.Status == "playing" {
+			return it.ID, true
+		}
+	}
+	return 0, false
+}
+
+func findItem(id int) (*TTSItem, bool) {
+	for _, it := range ttsQueue {
+		if it.ID == id {
+			return it, true
+		}
+	}
+	for _, it := range ttsHistory {
+		if it.ID == id {
+			return it, true
+		}
+	}
+	return nil, false
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// HTTP
+// ─────────────────────────────────────────────────────────────────────────────
+
+// handleSkip stops speech: POST /api/tts/skip[?id=]. Without an id it
+// skips whatever has been playing longest.
+func handleSkip(w http.ResponseWriter, r *http.Request) {
+	var id int
+	if r.URL.Query().Has("id") {
+		var ok bool
+		if id, ok = api.QueryInt(w, r, "id"); !ok {
+			return
+		}
+	} else if playing, ok := oldestPlaying(); ok {
+		id = playing
+	} else {
+		api.Conflict(w, "nothing is playing")
+		return
+	}
+	it, ok := finishPlaying(id, "skipped")
+	if !ok {
+		ttsMu.Lock()
+		cur, found := findItem(id)
+		status := ""
+		if found {
+			status = cur.Status
+		}
+		ttsMu.Unlock()
+		if !found {
+			api.NotFound(w, "tts item")
+			return
+		}
+		api.Conflict(w, fmt.Sprintf("tts item %d is %s, not playing", id, status))
+		return
+	}
+	ws.Broadcast(ws.WSMsg{Type: "TTS_STOP", Data: map[string]any{"id": id}})
+	mPlayback.Inc("skipped")
+	logger.InfoContext(r.Context(), "tts skipped", "id", id)
+	api.OK(w, it)
+}
+
+// handleReplay plays a finished item again: POST /api/tts/replay?id=
+func handleReplay(w http.ResponseWriter, r *http.Request) {
+	id, ok := api.QueryInt(w, r, "id")
+	if !ok {
+		return
+	}
+	ttsMu.Lock()
+	it, found := findItem(id)
+	var snap TTSItem
+	if found {
+		snap = *it
+	}
+	replayable := found && (snap.Status == "spoken" || snap.Status == "skipped")
+	if replayable {
+		it.Replays++
+		snap.Replays = it.Replays
+	}
+	ttsMu.Unlock()
+	switch {
+	case !found:
+		api.NotFound(w, "tts item")
+		return
+	case !replayable:
+		api.Conflict(w, fmt.Sprintf("tts item %d is %s; only spoken or skipped items can be replayed", id, snap.Status))
+		return
+	}
+	ws.Broadcast(ws.WSMsg{Type: "TTS_PLAY", Data: playPayload(r.Context(), snap)})
+	mPlayback.Inc("replay")
+	logger.InfoContext(r.Context(), "tts replayed", "id", id)
+	api.OK(w, snap)
+}
+
+// handleHistory pages through finished items, newest first:
+// GET /api/tts/history?status=spoken,skipped&limit=50&offset=0
+func handleHistory(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := api.QueryPage(w, r, 50, 200)
+	if !ok {
+		return
+	}
+	want := map[string]bool{}
+	if s := r.URL.Query().Get("status"); s != "" {
+		for _, st := range strings.Split(s, ",") {
+			st = strings.TrimSpace(st)
+			if !terminal(st) {
+				api.FieldError(w, http.StatusBadRequest, api.CodeBadRequest, "status", "unknown history status "+st)
+				return
+			}
+			want[st] = true
+		}
+	}
+	ttsMu.Lock()
+	matched := []TTSItem{}
+	for i := len(ttsHistory) - 1; i >= 0; i-- {
+		if it := ttsHistory[i]; len(want) == 0 || want[it.Status] {
+			matched = append(matched, *it)
+		}
+	}
+	ttsMu.Unlock()
+	total := len(matched)
+	items := matched[min(offset, total):min(offset+limit, total)]
+	api.OK(w, api.Page{Items: items, Total: total, Limit: limit, Offset: offset})
+}
+
+// speakItem announces the donation (if any), sends TTS_PLAY and marks the
+// item playing until an overlay reports TTS_DONE. It returns a snapshot of
+// the updated item.
+func speakItem(ctx context.Context, it *TTSItem) TTSItem {
+	ttsMu.Lock()
+	snap := *it
+	ttsMu.Unlock()
+	if snap.Donor != "" || snap.AmountCents > 0 || snap.Msg != "" {
+		ws.Broadcast(ws.WSMsg{Type: "DONATION", Data: map[string]any{"donor": snap.Donor, "amount": snap.AmountCents, "msg": snap.Msg}})
+	}
+	data := playPayload(ctx, snap)
+	ttsMu.Lock()
+	it.Status = "playing"
+	it.PlayedUnix = time.Now().Unix()
+	snap = *it
+	ttsMu.Unlock()
+	ws.Broadcast(ws.WSMsg{Type: "TTS_PLAY", Data: data})
+	clip, _ := data["duration_ms"].(int64)
+	watchPlayback(snap.ID, time.Duration(clip)*time.Millisecond)
+	return snap
+}
@@ -0,0 +1,270 @@
+package tts
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+)
+
+// QueueConfig controls how pending items are ordered and how long they may
+// wait. It is persisted with the rest of the state.
+type QueueConfig struct {
+	Tiers         []int64 `json:"tiers"`          // ascending amount thresholds in cents; an item's tier is how many it reaches
+	ExpireMinutes int     `json:"expire_minutes"` // pending items older than this expire; 0 disables
+	HistoryLimit  int     `json:"history_limit"`  // terminal items kept after compaction; at least 1
+}
+
+// DefaultQueueConfig is used until an operator saves their own settings.
+func DefaultQueueConfig() QueueConfig {
+	return QueueConfig{
+		Tiers:         []int64{500, 2000, 10000},
+		ExpireMinutes: 30,
+		HistoryLimit:  500,
+	}
+}
+
+var (
+	queueMu  sync.Mutex
+	queueCfg = DefaultQueueConfig()
+
+	// ttsHistory holds items compacted out of ttsQueue, oldest first.
+	// Guarded by ttsMu together with the queue.
+	ttsHistory = []*TTSItem{}
+)
+
+// GetQueueConfig returns the current queue settings.
+func GetQueueConfig() QueueConfig {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	c := queueCfg
+	c.Tiers = append([]int64{}, queueCfg.Tiers...)
+	return c
+}
+
+// SetQueueConfig replaces the queue settings (used by state.LoadState). A
+// zero HistoryLimit comes from state saved before the setting existed and
+// gets the default.
+func SetQueueConfig(c QueueConfig) {
+	if c.Tiers == nil {
+		c.Tiers = []int64{}
+	}
+	if c.HistoryLimit <= 0 {
+		c.HistoryLimit = DefaultQueueConfig().HistoryLimit
+	}
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	queueCfg = c
+}
+
+// tierFor returns how many tier thresholds amountCents reaches.
+func tierFor(amountCents int64, tiers []int64) int {
+	t := 0
+	for _, min := range tiers {
+		if amountCents >= min {
+			t++
+		}
+	}
+	return t
+}
+
+func terminal(status string) bool {
+	switch status {
+	case "rejected", "spoken", "skipped", "expired":
+		return true
+	}
+	return false
+}
+
+// enqueueLocked adds it to the live queue. Pending items go ahead of every
+// pending item in a lower tier and behind everything else, so equal tiers
+// keep arrival order and manual moves are left alone.
+func enqueueLocked(it *TTSItem) {
+	at := len(ttsQueue)
+	if it.Status == "pending" {
+		for i, q := range ttsQueue {
+			if q.Status == "pending" && q.Tier < it.Tier {
+				at = i
+				break
+			}
+		}
+	}
+	ttsQueue = append(ttsQueue, nil)
+	copy(ttsQueue[at+1:], ttsQueue[at:])
+	ttsQueue[at] = it
+	compactLocked()
+}
+
+// compactLocked moves rejected, spoken, skipped and expired items out of the live
+// queue into history, trimming history to the configured limit.
+func compactLocked() {
+	now := time.Now().Unix()
+	live := ttsQueue[:0]
+	for _, it := range ttsQueue {
+		if !terminal(it.Status) {
+			live = append(live, it)
+			continue
+		}
+		if it.FinishedUnix == 0 {
+			it.FinishedUnix = now
+		}
+		ttsHistory = append(ttsHistory, it)
+	}
+	clear(ttsQueue[len(live):])
+	ttsQueue = live
+	if limit := GetQueueConfig().HistoryLimit; len(ttsHistory) > limit {
+		ttsHistory = append([]*TTSItem{}, ttsHistory[len(ttsHistory)-limit:]...)
+	}
+}
+
+// moveLocked puts the pending item id at position to among pending items.
+func moveLocked(id, to int) (found bool, status string) {
+	from := -1
+	for i, it := range ttsQueue {
+		if it.ID == id {
+			from = i
+			break
+		}
+	}
+	if from < 0 {
+		for _, h := range ttsHistory {
+			if h.ID == id {
+				return true, h.Status
+			}
+		}
+		return false, ""
+	}
+	it := ttsQueue[from]
+	if it.Status != "pending" {
+		return true, it.Status
+	}
+	ttsQueue = append(ttsQueue[:from], ttsQueue[from+1:]...)
+	at, seen := len(ttsQueue), 0
+	for i, q := range ttsQueue {
+		if q.Status != "pending" {
+			continue
+		}
+		if seen == to {
+			at = i
+			break
+		}
+		seen++
+	}
+	ttsQueue = append(ttsQueue, nil)
+	copy(ttsQueue[at+1:], ttsQueue[at:])
+	ttsQueue[at] = it
+	return true, "pending"
+}
+
+// expireStale marks pending items older than the configured age as expired
+// and compacts them away. It returns the IDs it expired.
+func expireStale(now time.Time) []int {
+	mins := GetQueueConfig().ExpireMinutes
+	if mins <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-time.Duration(mins) * time.Minute).Unix()
+	ttsMu.Lock()
+	defer ttsMu.Unlock()
+	var ids []int
+	for _, it := range ttsQueue {
+		if it.Status == "pending" && it.CreatedUnix < cutoff {
+			it.Status = "expired"
+			ids = append(ids, it.ID)
+		}
+	}
+	if len(ids) > 0 {
+		compactLocked()
+	}
+	return ids
+}
+
+// StartExpiry sweeps the queue for stale pending items every interval.
+func StartExpiry(interval time.Duration) {
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for now := range t.C {
+			if ids := expireStale(now); len(ids) > 0 {
+				mDecisions.Add(float64(len(ids)), "expired")
+				logger.Info("tts items expired", "ids", ids)
+			}
+		}
+	}()
+}
+
+// GetHistory returns a copy of the compacted items, oldest first.
+func GetHistory() []*TTSItem {
+	ttsMu.Lock()
+	defer ttsMu.Unlock()
+	out := make([]*TTSItem, len(ttsHistory))
+	copy(out, ttsHistory)
+	return out
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// HTTP
+// ─────────────────────────────────────────────────────────────────────────────
+
+// handleMove reorders the pending list: POST /api/tts/move?id=3&to=0
+// (to is a 0-based position among pending items; past the end means last).
+func handleMove(w http.ResponseWriter, r *http.Request) {
+	id, ok := api.QueryInt(w, r, "id")
+	if !ok {
+		return
+	}
+	to, ok := api.QueryInt(w, r, "to")
+	if !ok {
+		return
+	}
+	if to < 0 {
+		api.Invalid(w, "to", "to must not be negative")
+		return
+	}
+	ttsMu.Lock()
+	found, status := moveLocked(id, to)
+	ttsMu.Unlock()
+	switch {
+	case !found:
+		api.NotFound(w, "tts item")
+		return
+	case status != "pending":
+		api.Conflict(w, fmt.Sprintf("tts item %d is %s, not pending", id, status))
+		return
+	}
+	logger.InfoContext(r.Context(), "tts item moved", "id", id, "to", to)
+	api.OK(w, ttsListPending())
+}
+
+func handleGetQueueConfig(w http.ResponseWriter, r *http.Request) {
+	api.OK(w, GetQueueConfig())
+}
+
+func handlePutQueueConfig(w http.ResponseWriter, r *http.Request) {
+	var c QueueConfig
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		api.Error(w, http.StatusBadRequest, api.CodeBadRequest, "invalid JSON payload")
+		return
+	}
+	switch {
+	case !sort.SliceIsSorted(c.Tiers, func(i, j int) bool { return c.Tiers[i] < c.Tiers[j] }):
+		api.Invalid(w, "tiers", "tiers must be in ascending order")
+		return
+	case len(c.Tiers) > 0 && c.Tiers[0] <= 0:
+		api.Invalid(w, "tiers", "tiers must be positive")
+		return
+	case c.ExpireMinutes < 0:
+		api.Invalid(w, "expire_minutes", "expire_minutes must not be negative")
+		return
+	case c.HistoryLimit < 1:
+		api.Invalid(w, "history_limit", "history_limit must be at least 1")
+		return
+	}
+	SetQueueConfig(c)
+	logger.InfoContext(r.Context(), "tts queue config updated", "tiers", c.Tiers, "expire_minutes", c.ExpireMinutes)
+	api.OK(w, GetQueueConfig())
+}
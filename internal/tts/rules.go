@@ -0,0 +1,173 @@
+package tts
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+)
+
+// Rule auto-approves a submission when every condition it sets holds.
+// Rules are evaluated in order and the first match wins.
+type Rule struct {
+	ID             string   `json:"id"`
+	Name           string   `json:"name,omitempty"`
+	Enabled        bool     `json:"enabled"`
+	MinAmountCents int64    `json:"min_amount_cents,omitempty"` // amount ≥ this
+	RequireClean   bool     `json:"require_clean,omitempty"`    // no moderation flags at all
+	MaxScore       int      `json:"max_score,omitempty"`        // moderation score ≤ this (0 = unchecked)
+	Donors         []string `json:"donors,omitempty"`           // donor is on this list (case-insensitive)
+}
+
+// AutoApproveConfig is the rule set plus the global kill switch.
+type AutoApproveConfig struct {
+	ManualOnly bool   `json:"manual_only"` // when true no rule fires
+	Rules      []Rule `json:"rules"`
+}
+
+var (
+	rulesMu   sync.Mutex
+	rulesConf = AutoApproveConfig{Rules: []Rule{}}
+)
+
+// GetAutoApprove returns the current rule set.
+func GetAutoApprove() AutoApproveConfig {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	c := rulesConf
+	c.Rules = append([]Rule{}, rulesConf.Rules...)
+	return c
+}
+
+// SetAutoApprove replaces the rule set (used by state.LoadState).
+func SetAutoApprove(c AutoApproveConfig) {
+	if c.Rules == nil {
+		c.Rules = []Rule{}
+	}
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rulesConf = c
+}
+
+func (r Rule) hasCondition() bool {
+	return r.MinAmountCents > 0 || r.RequireClean || r.MaxScore > 0 || len(r.Donors) > 0
+}
+
+func (r Rule) matches(it TTSItem) bool {
+	if !r.Enabled || !r.hasCondition() {
+		return false
+	}
+	if r.MinAmountCents > 0 && it.AmountCents < r.MinAmountCents {
+		return false
+	}
+	if r.RequireClean && len(it.Flags) > 0 {
+		return false
+	}
+	if r.MaxScore > 0 && it.Score > r.MaxScore {
+		return false
+	}
+	if len(r.Donors) > 0 {
+		trusted := false
+		for _, d := range r.Donors {
+			if strings.EqualFold(strings.TrimSpace(d), strings.TrimSpace(it.Donor)) && it.Donor != "" {
+				trusted = true
+				break
+			}
+		}
+		if !trusted {
+			return false
+		}
+	}
+	return true
+}
+
+// autoApproveRule returns the ID of the first rule that approves it, or ""
+// when the item needs a human (including when manual-only is on).
+func autoApproveRule(it TTSItem) string {
+	c := GetAutoApprove()
+	if c.ManualOnly {
+		return ""
+	}
+	for _, r := range c.Rules {
+		if r.matches(it) {
+			return r.ID
+		}
+	}
+	return ""
+}
+
+func validateRules(rules []Rule) (field, msg string) {
+	seen := map[string]bool{}
+	for i, r := range rules {
+		f := fmt.Sprintf("rules[%d]", i)
+		switch {
+		case strings.TrimSpace(r.ID) == "":
+			return f + ".id", "rule id is required"
+		case seen[r.ID]:
+			return f + ".id", "duplicate rule id " + r.ID
+		case r.ID == "manual":
+			return f + ".id", `"manual" is reserved`
+		case !r.hasCondition():
+			return f, "rule must set at least one condition"
+		case r.MinAmountCents < 0 || r.MaxScore < 0:
+			return f, "thresholds must not be negative"
+		}
+		seen[r.ID] = true
+	}
+	return "", ""
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// HTTP
+// ─────────────────────────────────────────────────────────────────────────────
+
+func handleGetRules(w http.ResponseWriter, r *http.Request) {
+	api.OK(w, GetAutoApprove())
+}
+
+// handlePutRules replaces the rule list; manual_only is left alone unless
+// the body sets it.
+func handlePutRules(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ManualOnly *bool  `json:"manual_only"`
+		Rules      []Rule `json:"rules"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		api.Error(w, http.StatusBadRequest, api.CodeBadRequest, "invalid JSON payload")
+		return
+	}
+	if field, msg := validateRules(body.Rules); field != "" {
+		api.Invalid(w, field, msg)
+		return
+	}
+	c := GetAutoApprove()
+	c.Rules = body.Rules
+	if body.ManualOnly != nil {
+		c.ManualOnly = *body.ManualOnly
+	}
+	SetAutoApprove(c)
+	logger.InfoContext(r.Context(), "tts auto-approve rules updated", "rules", len(c.Rules), "manual_only", c.ManualOnly)
+	api.OK(w, GetAutoApprove())
+}
+
+// handleManualOnly flips the kill switch: POST /api/tts/manual-only?enabled=true
+func handleManualOnly(w http.ResponseWriter, r *http.Request) {
+	raw, ok := api.QueryString(w, r, "enabled")
+	if !ok {
+		return
+	}
+	on, err := strconv.ParseBool(raw)
+	if err != nil {
+		api.FieldError(w, http.StatusBadRequest, api.CodeBadRequest, "enabled", "enabled must be true or false")
+		return
+	}
+	c := GetAutoApprove()
+	c.ManualOnly = on
+	SetAutoApprove(c)
+	logger.InfoContext(r.Context(), "tts manual-only switched", "manual_only", on)
+	api.OK(w, c)
+}
@@ -0,0 +1,424 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
+	"github.com/go-chi/chi/v5"
+)
+
+// SynthRequest is one piece of text to render. Zero Rate/Pitch mean "engine
+// default"; Rate is a multiplier (1.0 = normal speed), Pitch is 0–2 with 1.0
+// as neutral.
+type SynthRequest struct {
+	Text  string
+	Voice string
+	Rate  float64
+	Pitch float64
+}
+
+// Audio is a rendered clip sitting in the cache directory.
+type Audio struct {
+	ID       string        `json:"id"`
+	Format   string        `json:"format"` // "wav" or "ogg"
+	Duration time.Duration `json:"duration"`
+	Path     string        `json:"-"`
+}
+
+// URL is where overlays fetch the clip from.
+func (a Audio) URL() string { return "/tts/audio/" + a.ID }
+
+// Synthesizer renders text to an audio file on disk.
+type Synthesizer interface {
+	Name() string
+	Synthesize(ctx context.Context, req SynthRequest) (Audio, error)
+}
+
+var (
+	synthMu sync.RWMutex
+	synth   Synthesizer // nil → overlays fall back to browser speechSynthesis
+
+	mSynth = metrics.NewCounter("overlay_tts_synth_total", "Server-side TTS renders by result.", "result")
+)
+
+// SetSynthesizer installs the engine used when items are approved.
+func SetSynthesizer(s Synthesizer) {
+	synthMu.Lock()
+	defer synthMu.Unlock()
+	synth = s
+}
+
+func currentSynth() Synthesizer {
+	synthMu.RLock()
+	defer synthMu.RUnlock()
+	return synth
+}
+
+// SetupSynthesizer configures the engine from the environment:
+//
+//	TTS_ENGINE     espeak-ng | piper | none | auto (default: espeak-ng if installed)
+//	TTS_ENGINE_BIN path to the engine binary (default: looked up in $PATH)
+//	TTS_CACHE_DIR  where rendered clips live (default: tts-cache)
+//	TTS_CACHE_MB   size the cache is trimmed to, least recently used first (default 200; 0 = no limit)
+//	TTS_FORMAT     wav | ogg (ogg needs ffmpeg; default wav)
+//	PIPER_MODEL_DIR directory holding <voice>.onnx models for piper
+func SetupSynthesizer() {
+	engine := os.Getenv("TTS_ENGINE")
+	if engine == "" || engine == "auto" {
+		engine = "none"
+		if _, err := exec.LookPath("espeak-ng"); err == nil {
+			engine = "espeak-ng"
+		}
+	}
+	if engine == "none" {
+		logger.Info("server-side tts disabled; overlays will use browser speech")
+		return
+	}
+	bin := os.Getenv("TTS_ENGINE_BIN")
+	if bin == "" {
+		bin = engine
+	}
+	if _, err := exec.LookPath(bin); err != nil {
+		logger.Warn("tts engine not found; overlays will use browser speech", "engine", engine, "err", err)
+		return
+	}
+	cacheDir := os.Getenv("TTS_CACHE_DIR")
+	if cacheDir == "" {
+		cacheDir = "tts-cache"
+	}
+	format := os.Getenv("TTS_FORMAT")
+	if format != "ogg" {
+		format = "wav"
+	}
+	cacheMB := 200
+	if v := os.Getenv("TTS_CACHE_MB"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			logger.Warn("bad TTS_CACHE_MB; using default", "value", v, "default", cacheMB)
+		} else {
+			cacheMB = n
+		}
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		logger.Error("cannot create tts cache dir", "dir", cacheDir, "err", err)
+		return
+	}
+	s := &ExecSynthesizer{
+		Engine:   engine,
+		Bin:      bin,
+		CacheDir: cacheDir,
+		Format:   format,
+		ModelDir: os.Getenv("PIPER_MODEL_DIR"),
+		MaxBytes: int64(cacheMB) << 20,
+	}
+	s.prune()
+	SetSynthesizer(s)
+	logger.Info("server-side tts enabled", "engine", engine, "cache_dir", cacheDir, "format", format, "cache_mb", cacheMB)
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// ExecSynthesizer: shells out to espeak-ng or piper
+// ─────────────────────────────────────────────────────────────────────────────
+
+// ExecSynthesizer renders through a local command-line engine and caches
+// the result on disk, keyed by engine + voice + parameters + text. Clips
+// are shared by every item with the same text, so they are evicted by size
+// rather than with the items that used them.
+type ExecSynthesizer struct {
+	Engine   string // "espeak-ng" or "piper"
+	Bin      string
+	CacheDir string
+	Format   string // "wav" or "ogg"
+	ModelDir string // piper only
+	MaxBytes int64  // cache size cap; 0 = unlimited
+
+	mu sync.Mutex // serialises renders so a burst doesn't fork dozens of engines
+}
+
+func (e *ExecSynthesizer) Name() string { return e.Engine }
+
+func (e *ExecSynthesizer) cacheKey(req SynthRequest) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%g\x00%g\x00%s\x00%s", e.Engine, req.Voice, req.Rate, req.Pitch, e.Format, req.Text)
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+func (e *ExecSynthesizer) Synthesize(ctx context.Context, req SynthRequest) (Audio, error) {
+	id := e.cacheKey(req)
+	out := Audio{ID: id, Format: e.Format, Path: filepath.Join(e.CacheDir, id+"."+e.Format)}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if meta, err := readMeta(e.CacheDir, id); err == nil {
+		if _, err := os.Stat(out.Path); err == nil {
+			mSynth.Inc("hit")
+			// mark it recently used so prune keeps it
+			now := time.Now()
+			os.Chtimes(out.Path, now, now)
+			out.Duration = meta.Duration
+			return out, nil
+		}
+	}
+
+	wav := filepath.Join(e.CacheDir, id+".tmp.wav")
+	defer os.Remove(wav)
+	if err := e.render(ctx, req, wav); err != nil {
+		mSynth.Inc("error")
+		return Audio{}, err
+	}
+	dur, err := wavDuration(wav)
+	if err != nil {
+		mSynth.Inc("error")
+		return Audio{}, err
+	}
+	if e.Format == "ogg" {
+		cmd := exec.CommandContext(ctx, "ffmpeg", "-y", "-loglevel", "error", "-i", wav, "-c:a", "libvorbis", out.Path)
+		if b, err := cmd.CombinedOutput(); err != nil {
+			mSynth.Inc("error")
+			return Audio{}, fmt.Errorf("ffmpeg: %v: %s", err, bytes.TrimSpace(b))
+		}
+	} else if err := os.Rename(wav, out.Path); err != nil {
+		mSynth.Inc("error")
+		return Audio{}, err
+	}
+	out.Duration = dur
+	if err := writeMeta(e.CacheDir, out); err != nil {
+		logger.Warn("tts cache meta write failed", "id", id, "err", err)
+	}
+	mSynth.Inc("miss")
+	e.prune()
+	return out, nil
+}
+
+// prune deletes the least recently used clips (and their metadata) until
+// the cache fits in MaxBytes. Callers other than SetupSynthesizer hold e.mu.
+func (e *ExecSynthesizer) prune() {
+	if e.MaxBytes <= 0 {
+		return
+	}
+	type clip struct {
+		id   string
+		size int64
+		used time.Time
+	}
+	entries, err := os.ReadDir(e.CacheDir)
+	if err != nil {
+		logger.Warn("tts cache prune failed", "dir", e.CacheDir, "err", err)
+		return
+	}
+	var clips []clip
+	var total int64
+	for _, de := range entries {
+		id, ext, _ := strings.Cut(de.Name(), ".")
+		if !audioID.MatchString(id) || (ext != "wav" && ext != "ogg") {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		clips = append(clips, clip{id: id, size: info.Size(), used: info.ModTime()})
+		total += info.Size()
+	}
+	if total <= e.MaxBytes {
+		return
+	}
+	sort.Slice(clips, func(i, j int) bool { return clips[i].used.Before(clips[j].used) })
+	n := 0
+	for _, c := range clips {
+		if total <= e.MaxBytes {
+			break
+		}
+		for _, ext := range []string{".wav", ".ogg", ".json"} {
+			os.Remove(filepath.Join(e.CacheDir, c.id+ext))
+		}
+		total -= c.size
+		n++
+	}
+	logger.Info("tts cache pruned", "clips", n, "bytes_left", total)
+}
+
+func (e *ExecSynthesizer) render(ctx context.Context, req SynthRequest, wav string) error {
+	if req.Voice != "" && !engineVoicePattern.MatchString(req.Voice) {
+		return fmt.Errorf("refusing engine voice %q", req.Voice)
+	}
+	var cmd *exec.Cmd
+	switch e.Engine {
+	case "espeak-ng":
+		args := []string{"-w", wav}
+		if req.Voice != "" {
+			args = append(args, "-v", req.Voice)
+		}
+		if req.Rate > 0 {
+			args = append(args, "-s", strconv.Itoa(int(175*req.Rate))) // 175 wpm is espeak's default
+		}
+		if req.Pitch > 0 {
+			args = append(args, "-p", strconv.Itoa(clampInt(int(50*req.Pitch), 0, 99)))
+		}
+		cmd = exec.CommandContext(ctx, e.Bin, append(args, "--stdin")...)
+	case "piper":
+		if req.Voice == "" {
+			return errors.New("piper needs a voice model")
+		}
+		args := []string{"--model", filepath.Join(e.ModelDir, req.Voice+".onnx"), "--output_file", wav}
+		if req.Rate > 0 {
+			args = append(args, "--length_scale", strconv.FormatFloat(1/req.Rate, 'f', 2, 64))
+		}
+		cmd = exec.CommandContext(ctx, e.Bin, args...)
+	default:
+		return fmt.Errorf("unknown tts engine %q", e.Engine)
+	}
+	cmd.Stdin = bytes.NewBufferString(req.Text)
+	if b, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v: %s", e.Engine, err, bytes.TrimSpace(b))
+	}
+	return nil
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// Cache metadata & WAV parsing
+// ─────────────────────────────────────────────────────────────────────────────
+
+func metaPath(dir, id string) string { return filepath.Join(dir, id+".json") }
+
+func readMeta(dir, id string) (Audio, error) {
+	var a Audio
+	b, err := os.ReadFile(metaPath(dir, id))
+	if err != nil {
+		return a, err
+	}
+	return a, json.Unmarshal(b, &a)
+}
+
+func writeMeta(dir string, a Audio) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metaPath(dir, a.ID), b, 0644)
+}
+
+// wavDuration reads the RIFF header to work out how long a PCM clip is.
+func wavDuration(path string) (time.Duration, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) < 12 || string(b[0:4]) != "RIFF" || string(b[8:12]) != "WAVE" {
+		return 0, errors.New("not a WAV file")
+	}
+	var byteRate uint32
+	for off := 12; off+8 <= len(b); {
+		id := string(b[off : off+4])
+		size := binary.LittleEndian.Uint32(b[off+4 : off+8])
+		body := off + 8
+		switch id {
+		case "fmt ":
+			if body+12 > len(b) {
+				return 0, errors.New("truncated fmt chunk")
+			}
+			byteRate = binary.LittleEndian.Uint32(b[body+8 : body+12])
+		case "data":
+			if byteRate == 0 {
+				return 0, errors.New("data chunk before fmt chunk")
+			}
+			// engines streaming to a file sometimes leave size as 0xFFFFFFFF
+			if int(size) > len(b)-body || size == 0xFFFFFFFF {
+				size = uint32(len(b) - body)
+			}
+			return time.Duration(float64(size) / float64(byteRate) * float64(time.Second)), nil
+		}
+		off = body + int(size) + int(size&1)
+	}
+	return 0, errors.New("no data chunk")
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// HTTP
+// ─────────────────────────────────────────────────────────────────────────────
+
+var audioID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// serveAudio streams a cached clip: GET /tts/audio/{id}.
+func serveAudio(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !audioID.MatchString(id) {
+		api.FieldError(w, http.StatusBadRequest, api.CodeBadRequest, "id", "malformed audio id")
+		return
+	}
+	es, ok := currentSynth().(*ExecSynthesizer)
+	if !ok {
+		api.NotFound(w, "audio clip")
+		return
+	}
+	meta, err := readMeta(es.CacheDir, id)
+	if err != nil {
+		api.NotFound(w, "audio clip")
+		return
+	}
+	ctype := "audio/wav"
+	if meta.Format == "ogg" {
+		ctype = "audio/ogg"
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Cache-Control", "public, max-age=86400, immutable")
+	http.ServeFile(w, r, filepath.Join(es.CacheDir, id+"."+meta.Format))
+}
+
+// playPayload builds the TTS_PLAY message, rendering audio server-side when
+// an engine is configured. On failure it degrades to text-only so the
+// overlay can still fall back to browser speech; the normalized text, voice,
+// rate and pitch are always sent for that case.
+func playPayload(ctx context.Context, it TTSItem) map[string]any {
+	s := currentSynth()
+	engine := ""
+	if s != nil {
+		engine = s.Name()
+	}
+	req, hint := synthParams(it.Voice, engine)
+	req.Text = Normalize(it.Text)
+	data := map[string]any{"id": it.ID, "text": req.Text, "voice": hint, "rate": req.Rate, "pitch": req.Pitch}
+	if s == nil {
+		return data
+	}
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+	a, err := s.Synthesize(ctx, req)
+	if err != nil {
+		logger.WarnContext(ctx, "tts synthesis failed; falling back to browser speech", "id", it.ID, "engine", s.Name(), "err", err)
+		return data
+	}
+	data["url"] = a.URL()
+	data["duration_ms"] = a.Duration.Milliseconds()
+	data["format"] = a.Format
+	return data
+}
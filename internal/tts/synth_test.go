@@ -0,0 +1,47 @@
+package tts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPruneEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	e := &ExecSynthesizer{CacheDir: dir, Format: "wav", MaxBytes: 250}
+	base := time.Now().Add(-time.Hour)
+	ids := []string{
+		"00000000000000000000000000000001",
+		"00000000000000000000000000000002",
+		"00000000000000000000000000000003",
+	}
+	for i, id := range ids {
+		clip := filepath.Join(dir, id+".wav")
+		if err := os.WriteFile(clip, make([]byte, 100), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeMeta(dir, Audio{ID: id, Format: "wav"}); err != nil {
+			t.Fatal(err)
+		}
+		used := base.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(clip, used, used)
+	}
+	os.WriteFile(filepath.Join(dir, "unrelated.txt"), make([]byte, 1000), 0644)
+
+	e.prune()
+
+	for i, id := range ids {
+		_, err := os.Stat(filepath.Join(dir, id+".wav"))
+		_, metaErr := os.Stat(metaPath(dir, id))
+		if kept := err == nil; kept != (i > 0) {
+			t.Errorf("clip %d kept = %v, want %v", i, kept, i > 0)
+		}
+		if (err == nil) != (metaErr == nil) {
+			t.Errorf("clip %d: audio and metadata disagree", i)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "unrelated.txt")); err != nil {
+		t.Errorf("prune removed a file that isn't a clip: %v", err)
+	}
+}
@@ -1,25 +1,40 @@
 package tts
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
 	"net/http"
-	"strconv"
 	"sync"
 	"time"
 
+	"github.com/dtorres47/stream-overlay/internal/api"
+	"github.com/dtorres47/stream-overlay/internal/logging"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
 	"github.com/dtorres47/stream-overlay/internal/ws"
 	"github.com/go-chi/chi/v5"
 )
 
+var logger = logging.For("tts")
+
 type TTSItem struct {
-	ID          int    `json:"id"`
-	Text        string `json:"text"`
-	Voice       string `json:"voice"`
-	Donor       string `json:"donor"`
-	AmountCents int64  `json:"amount_cents"`
-	Msg         string `json:"msg"`
-	CreatedUnix int64  `json:"created_unix"`
-	Status      string `json:"status"`
+	ID           int    `json:"id"`
+	Text         string `json:"text"`
+	Voice        string `json:"voice"`
+	Donor        string `json:"donor"`
+	AmountCents  int64  `json:"amount_cents"`
+	Msg          string `json:"msg"`
+	CreatedUnix  int64  `json:"created_unix"`
+	Status       string `json:"status"`
+	OriginalText string `json:"original_text,omitempty"` // set when moderation changed Text
+	Flags        []Flag `json:"flags,omitempty"`
+	Score        int    `json:"score,omitempty"`
+	RejectReason string `json:"reject_reason,omitempty"`
+	ApprovedBy   string `json:"approved_by,omitempty"`   // rule ID, or "manual"
+	Tier         int    `json:"tier"`                    // amount tier at submit time; higher plays first
+	VoiceWanted  string `json:"voice_wanted,omitempty"`  // donor's choice when it was above their tier
+	FinishedUnix int64  `json:"finished_unix,omitempty"` // when the item left the live queue
+	PlayedUnix   int64  `json:"played_unix,omitempty"`   // when TTS_PLAY went out
+	Replays      int    `json:"replays,omitempty"`
 }
 
 var (
@@ -28,6 +43,23 @@ var (
 	ttsQueue = []*TTSItem{}
 )
 
+var (
+	_ = metrics.NewGaugeFunc("overlay_tts_queue_depth", "TTS items awaiting moderation.", func() float64 {
+		return float64(PendingCount())
+	})
+	mDecisions = metrics.NewCounter("overlay_tts_decisions_total", "TTS moderation decisions.", "decision")
+)
+
+// submitResult is the submit response: the stored item plus what the donor
+// has left to work with.
+type submitResult struct {
+	TTSItem
+	CharLimit       int  `json:"char_limit"`       // characters this amount buys; 0 = unlimited
+	CharsRemaining  int  `json:"chars_remaining"`  // of char_limit, unused by this message
+	CooldownSeconds int  `json:"cooldown_seconds"` // until this donor may submit again
+	Truncated       bool `json:"truncated"`        // text was cut to char_limit
+}
+
 func ttsListPending() []TTSItem {
 	ttsMu.Lock()
 	defer ttsMu.Unlock()
@@ -40,83 +72,168 @@ func ttsListPending() []TTSItem {
 	return out
 }
 
-func ttsFind(id int) (*TTSItem, bool) {
-	ttsMu.Lock()
-	defer ttsMu.Unlock()
-	for _, it := range ttsQueue {
-		if it.ID == id {
-			return it, true
-		}
-	}
-	return nil, false
-}
-
 func RegisterRoutes(r *chi.Mux) {
 	r.Get("/api/tts/submit", func(w http.ResponseWriter, r *http.Request) {
 		q := r.URL.Query()
 		text := q.Get("text")
 		if text == "" {
-			http.Error(w, "missing ?text=", http.StatusBadRequest)
+			api.Invalid(w, "text", "text is required")
 			return
 		}
 		voice := q.Get("voice")
 		donor := q.Get("donor")
 		msg := q.Get("msg")
-		var amt int64
-		if v := q.Get("amount_cents"); v != "" {
-			if p, err := strconv.ParseInt(v, 10, 64); err == nil {
-				amt = p
-			}
+		amt, ok := api.QueryInt64(w, r, "amount_cents", 0)
+		if !ok {
+			return
+		}
+		if amt < 0 {
+			api.Invalid(w, "amount_cents", "amount_cents must not be negative")
+			return
+		}
+		tier := tierFor(amt, GetQueueConfig().Tiers)
+		voice, fellBack, err := resolveVoice(voice, tier)
+		if err != nil {
+			api.Invalid(w, "voice", err.Error())
+			return
+		}
+		cfg := GetModeration()
+		cfg.MaxLength = charLimit(amt, GetLimits().Budgets, cfg.MaxLength)
+		mod := moderate(text, cfg)
+		if mod.Text == "" {
+			api.Invalid(w, "text", "text is empty after moderation")
+			return
+		}
+		item := &TTSItem{Text: mod.Text, Voice: voice, Donor: donor, AmountCents: amt, Msg: msg, CreatedUnix: time.Now().Unix(), Status: "pending", Flags: mod.Flags, Score: mod.Score, Tier: tier}
+		if fellBack {
+			item.VoiceWanted = q.Get("voice")
+		}
+		if mod.Text != text {
+			item.OriginalText = text
+		}
+		if mod.rejected(cfg) {
+			item.Status = "rejected"
+			item.RejectReason = "auto: " + flagCodes(mod.Flags)
+		} else if rule := autoApproveRule(*item); rule != "" {
+			item.Status = "approved"
+			item.ApprovedBy = rule
+		}
+		cooldown, ok := claimCooldown(donor, time.Now())
+		if !ok {
+			api.TooMany(w, "donor", cooldown, fmt.Sprintf("this donor can send another message in %.0fs", cooldown.Seconds()))
+			return
+		}
+		// counted only once the item is sure to join the queue
+		for _, f := range item.Flags {
+			mFlags.Inc(f.Code)
+		}
+		switch item.Status {
+		case "rejected":
+			mDecisions.Inc("auto_rejected")
+		case "approved":
+			mDecisions.Inc("auto_approved")
 		}
 		ttsMu.Lock()
 		ttsSeq++
-		item := &TTSItem{ID: ttsSeq, Text: text, Voice: voice, Donor: donor, AmountCents: amt, Msg: msg, CreatedUnix: time.Now().Unix(), Status: "pending"}
-		ttsQueue = append(ttsQueue, item)
+		item.ID = ttsSeq
+		enqueueLocked(item)
+		out := *item
 		ttsMu.Unlock()
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(item)
+		logger.InfoContext(r.Context(), "tts submitted", "id", out.ID, "donor", donor, "amount_cents", amt, "status", out.Status, "approved_by", out.ApprovedBy, "score", out.Score, "text", text)
+		if out.Status == "approved" {
+			// synthesis can take a while; don't hold the submitter's request open
+			go speakItem(context.WithoutCancel(r.Context()), item)
+		}
+		res := submitResult{TTSItem: out, CharLimit: cfg.MaxLength, CooldownSeconds: int(cooldown / time.Second), Truncated: mod.Truncated}
+		if cfg.MaxLength > 0 {
+			res.CharsRemaining = max(0, cfg.MaxLength-len([]rune(out.Text)))
+		}
+		api.Created(w, res)
 	})
 
+	ws.Handle("TTS_DONE", handleDone)
+
+	r.Get("/tts/audio/{id}", serveAudio)
+	r.Post("/api/tts/skip", handleSkip)
+	r.Post("/api/tts/replay", handleReplay)
+	r.Get("/api/tts/history", handleHistory)
+	r.Get("/api/tts/limits", handleGetLimits)
+	r.Put("/api/tts/limits", handlePutLimits)
+	r.Get("/api/tts/moderation", handleGetModeration)
+	r.Put("/api/tts/moderation", handlePutModeration)
+	r.Get("/api/tts/rules", handleGetRules)
+	r.Put("/api/tts/rules", handlePutRules)
+	r.Post("/api/tts/manual-only", handleManualOnly)
+	r.Post("/api/tts/move", handleMove)
+	r.Get("/api/tts/voices", handleListVoices)
+	r.Put("/api/tts/voices", handlePutVoices)
+	r.Get("/api/tts/normalize", handleGetNormalize)
+	r.Put("/api/tts/normalize", handlePutNormalize)
+	r.Get("/api/tts/normalize/preview", handlePreview)
+	r.Get("/api/tts/queue/config", handleGetQueueConfig)
+	r.Put("/api/tts/queue/config", handlePutQueueConfig)
+
 	r.Get("/api/tts/queue", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(ttsListPending())
+		api.OK(w, ttsListPending())
 	})
 
 	r.Post("/api/tts/approve", func(w http.ResponseWriter, r *http.Request) {
-		id, _ := strconv.Atoi(r.URL.Query().Get("id"))
-		it, ok := ttsFind(id)
-		if !ok || it.Status != "pending" {
-			http.Error(w, "unknown or not pending", http.StatusNotFound)
+		it, ok := takePending(w, r, "approved")
+		if !ok {
 			return
 		}
 		ttsMu.Lock()
-		it.Status = "approved"
+		it.ApprovedBy = "manual"
 		ttsMu.Unlock()
-		if it.Donor != "" || it.AmountCents > 0 || it.Msg != "" {
-			ws.Broadcast(ws.WSMsg{Type: "DONATION", Data: map[string]any{"donor": it.Donor, "amount": it.AmountCents, "msg": it.Msg}})
-		}
-		ws.Broadcast(ws.WSMsg{Type: "TTS_PLAY", Data: map[string]any{"text": it.Text, "voice": it.Voice}})
-		ttsMu.Lock()
-		it.Status = "spoken"
-		ttsMu.Unlock()
-		w.Write([]byte("ok"))
+		mDecisions.Inc("approved")
+		logger.InfoContext(r.Context(), "tts approved", "id", it.ID)
+		api.OK(w, speakItem(r.Context(), it))
 	})
 
 	r.Post("/api/tts/reject", func(w http.ResponseWriter, r *http.Request) {
-		id, _ := strconv.Atoi(r.URL.Query().Get("id"))
-		it, ok := ttsFind(id)
-		if !ok || it.Status != "pending" {
-			http.Error(w, "unknown or not pending", http.StatusNotFound)
+		it, ok := takePending(w, r, "rejected")
+		if !ok {
 			return
 		}
+		mDecisions.Inc("rejected")
+		logger.InfoContext(r.Context(), "tts rejected", "id", it.ID)
 		ttsMu.Lock()
-		it.Status = "rejected"
+		out := *it
 		ttsMu.Unlock()
-		w.Write([]byte("ok"))
+		api.OK(w, out)
 	})
 }
 
-// GetQueue returns a copy of the current TTS queue (all items, in order).
+// takePending moves the item named by ?id= from pending to status, writing
+// 400/404/409 and returning ok=false when that isn't possible.
+func takePending(w http.ResponseWriter, r *http.Request, status string) (*TTSItem, bool) {
+	id, ok := api.QueryInt(w, r, "id")
+	if !ok {
+		return nil, false
+	}
+	ttsMu.Lock()
+	defer ttsMu.Unlock()
+	for _, it := range ttsQueue {
+		if it.ID != id {
+			continue
+		}
+		if it.Status != "pending" {
+			api.Conflict(w, fmt.Sprintf("tts item %d is %s, not pending", id, it.Status))
+			return nil, false
+		}
+		it.Status = status
+		compactLocked()
+		return it, true
+	}
+	api.NotFound(w, "tts item")
+	return nil, false
+}
+
+// PendingCount returns the number of items awaiting moderation.
+func PendingCount() int { return len(ttsListPending()) }
+
+// GetQueue returns a copy of the live TTS queue (pending and in-flight items,
+// in play order). Finished items are in GetHistory.
 func GetQueue() []*TTSItem {
 	ttsMu.Lock()
 	defer ttsMu.Unlock()
@@ -133,13 +250,45 @@ func GetNextID() int {
 	return ttsSeq
 }
 
-// SetState replaces the in-memory TTS queue and sequence counter.
-// Used by state.LoadState to restore a saved session.
-func SetState(queue []*TTSItem, seq int) {
+// SetState replaces the in-memory TTS queue, history and sequence counter.
+// Used by state.LoadState to restore a saved session; finished items found
+// in an older queue snapshot are compacted into history.
+func SetState(queue, history []*TTSItem, seq int) {
 	ttsMu.Lock()
 	defer ttsMu.Unlock()
-	// copy incoming slice to avoid aliasing
+	// copy incoming slices to avoid aliasing
 	ttsQueue = make([]*TTSItem, len(queue))
 	copy(ttsQueue, queue)
+	ttsHistory = make([]*TTSItem, len(history))
+	copy(ttsHistory, history)
 	ttsSeq = seq
+	for _, it := range ttsQueue {
+		if it.Status == "playing" { // nobody is left to report TTS_DONE
+			it.Status = "spoken"
+		}
+	}
+	compactLocked()
+}
+
+// ResumeApproved speaks items left approved by a restart: their synthesis
+// was cut short, so nothing else would pick them up. Call it once the
+// synthesizer is set up.
+func ResumeApproved() {
+	ttsMu.Lock()
+	var todo []*TTSItem
+	for _, it := range ttsQueue {
+		if it.Status == "approved" {
+			todo = append(todo, it)
+		}
+	}
+	ttsMu.Unlock()
+	if len(todo) == 0 {
+		return
+	}
+	logger.Info("resuming approved tts items", "count", len(todo))
+	go func() {
+		for _, it := range todo {
+			speakItem(context.Background(), it)
+		}
+	}()
 }
@@ -0,0 +1,222 @@
+package tts
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+
+	"github.com/dtorres47/stream-overlay/internal/api"
+)
+
+// Voice is one entry in the voice catalog. Donors pick a voice by ID; it is
+// offered once their donation reaches MinTier (see QueueConfig.Tiers).
+type Voice struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Engine      string  `json:"engine,omitempty"`       // engine the voice is for; empty means any
+	EngineVoice string  `json:"engine_voice,omitempty"` // voice or model name passed to the engine; defaults to ID
+	Browser     string  `json:"browser,omitempty"`      // hint matched against browser voices when rendering falls back
+	Rate        float64 `json:"rate,omitempty"`         // 1.0 = normal; 0 = engine default
+	Pitch       float64 `json:"pitch,omitempty"`        // 0–2, 1.0 = neutral; 0 = engine default
+	MinTier     int     `json:"min_tier"`
+	Default     bool    `json:"default,omitempty"` // tier default for MinTier and above, until a higher default unlocks
+}
+
+type voicesFile struct {
+	Voices []Voice `json:"voices"`
+}
+
+var (
+	voicesMu sync.Mutex
+	voices   = defaultVoices()
+)
+
+func defaultVoices() []Voice {
+	var vf voicesFile
+	if err := json.Unmarshal(embeddedVoices, &vf); err != nil {
+		logger.Error("failed to parse embedded voice catalog", "err", err)
+		return []Voice{}
+	}
+	return vf.Voices
+}
+
+// GetVoices returns the voice catalog in display order.
+func GetVoices() []Voice {
+	voicesMu.Lock()
+	defer voicesMu.Unlock()
+	return append([]Voice{}, voices...)
+}
+
+// SetVoices replaces the voice catalog (used by state.LoadState).
+func SetVoices(v []Voice) {
+	if v == nil {
+		v = []Voice{}
+	}
+	voicesMu.Lock()
+	defer voicesMu.Unlock()
+	voices = append([]Voice{}, v...)
+}
+
+func lookupVoice(id string) (Voice, bool) {
+	for _, v := range GetVoices() {
+		if v.ID == id {
+			return v, true
+		}
+	}
+	return Voice{}, false
+}
+
+// tierDefaultVoice picks the default voice with the highest MinTier the
+// tier unlocks, or the first unlocked voice when none is marked default.
+func tierDefaultVoice(tier int) (Voice, bool) {
+	var best, first Voice
+	var haveBest, haveFirst bool
+	for _, v := range GetVoices() {
+		if v.MinTier > tier {
+			continue
+		}
+		if !haveFirst {
+			first, haveFirst = v, true
+		}
+		if v.Default && (!haveBest || v.MinTier > best.MinTier) {
+			best, haveBest = v, true
+		}
+	}
+	if haveBest {
+		return best, true
+	}
+	return first, haveFirst
+}
+
+// Voice IDs and engine voice names end up on an engine's command line (and
+// in piper's model path), so they are restricted to plain names; engine
+// voices may add one espeak-style "+variant".
+var (
+	voiceIDPattern     = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	engineVoicePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(\+[A-Za-z0-9_-]+)?$`)
+)
+
+// resolveVoice maps a donor's choice to a catalog voice ID. Unknown IDs
+// are an error; a voice above the donor's tier falls back to the tier
+// default. With an empty catalog there is no voice to pick, so every
+// item gets the engine's default (ID "").
+func resolveVoice(requested string, tier int) (id string, fellBack bool, err error) {
+	if len(GetVoices()) == 0 {
+		return "", requested != "", nil
+	}
+	if requested != "" {
+		v, ok := lookupVoice(requested)
+		if !ok {
+			return "", false, fmt.Errorf("unknown voice %q", requested)
+		}
+		if v.MinTier <= tier {
+			return v.ID, false, nil
+		}
+	}
+	def, _ := tierDefaultVoice(tier)
+	return def.ID, requested != "", nil
+}
+
+// synthParams turns an item's voice into engine parameters and the
+// browser hint sent alongside for the speechSynthesis fallback.
+func synthParams(voiceID, engine string) (req SynthRequest, browser string) {
+	v, ok := lookupVoice(voiceID)
+	if !ok {
+		// Not in the catalog (a free-text hint from before it, or a voice
+		// since removed): the engine uses its default.
+		return SynthRequest{}, voiceID
+	}
+	req = SynthRequest{Rate: v.Rate, Pitch: v.Pitch}
+	if v.Engine == "" || v.Engine == engine {
+		req.Voice = v.EngineVoice
+		if req.Voice == "" {
+			req.Voice = v.ID
+		}
+	}
+	return req, v.Browser
+}
+
+func validateVoices(vs []Voice) (field, msg string) {
+	seen := map[string]bool{}
+	free := false
+	for i, v := range vs {
+		f := fmt.Sprintf("voices[%d]", i)
+		switch {
+		case v.ID == "":
+			return f + ".id", "voice id is required"
+		case !voiceIDPattern.MatchString(v.ID):
+			return f + ".id", "voice id may only contain letters, digits, - and _"
+		case v.EngineVoice != "" && !engineVoicePattern.MatchString(v.EngineVoice):
+			return f + ".engine_voice", "engine_voice may only contain letters, digits, - and _, plus one +variant"
+		case seen[v.ID]:
+			return f + ".id", "duplicate voice id " + v.ID
+		case v.MinTier < 0:
+			return f + ".min_tier", "min_tier must not be negative"
+		case v.Rate < 0 || v.Pitch < 0 || v.Pitch > 2:
+			return f, "rate must not be negative and pitch must be 0–2"
+		}
+		seen[v.ID] = true
+		free = free || v.MinTier == 0
+	}
+	if len(vs) > 0 && !free {
+		return "voices", "at least one voice must have min_tier 0"
+	}
+	return "", ""
+}
+
+// ─────────────────────────────────────────────────────────────────────────────
+// HTTP
+// ─────────────────────────────────────────────────────────────────────────────
+
+// VoiceOption is a catalog entry as offered to donation pages.
+type VoiceOption struct {
+	Voice
+	MinAmountCents int64 `json:"min_amount_cents"`
+	Unlocked       bool  `json:"unlocked"`
+}
+
+// handleListVoices lists the catalog with unlock amounts; with
+// ?amount_cents= it also marks which voices that amount unlocks and which
+// one it gets by default.
+func handleListVoices(w http.ResponseWriter, r *http.Request) {
+	amt, ok := api.QueryInt64(w, r, "amount_cents", 0)
+	if !ok {
+		return
+	}
+	tiers := GetQueueConfig().Tiers
+	tier := tierFor(amt, tiers)
+	out := struct {
+		Voices  []VoiceOption `json:"voices"`
+		Default string        `json:"default,omitempty"`
+	}{Voices: []VoiceOption{}}
+	for _, v := range GetVoices() {
+		o := VoiceOption{Voice: v, Unlocked: v.MinTier <= tier}
+		if v.MinTier > 0 && v.MinTier <= len(tiers) {
+			o.MinAmountCents = tiers[v.MinTier-1]
+		} else if v.MinTier > len(tiers) {
+			o.MinAmountCents = -1 // no tier reaches it
+		}
+		out.Voices = append(out.Voices, o)
+	}
+	if def, ok := tierDefaultVoice(tier); ok {
+		out.Default = def.ID
+	}
+	api.OK(w, out)
+}
+
+func handlePutVoices(w http.ResponseWriter, r *http.Request) {
+	var body voicesFile
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		api.Error(w, http.StatusBadRequest, api.CodeBadRequest, "invalid JSON payload")
+		return
+	}
+	if field, msg := validateVoices(body.Voices); field != "" {
+		api.Invalid(w, field, msg)
+		return
+	}
+	SetVoices(body.Voices)
+	logger.InfoContext(r.Context(), "tts voice catalog updated", "voices", len(body.Voices))
+	api.OK(w, voicesFile{Voices: GetVoices()})
+}
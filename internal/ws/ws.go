@@ -2,19 +2,37 @@ package ws
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/dtorres47/stream-overlay/internal/logging"
+	"github.com/dtorres47/stream-overlay/internal/metrics"
 	"github.com/gorilla/websocket"
 )
 
+var logger = logging.For("ws")
+
 var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
 
 var (
-	clients   = make(map[*websocket.Conn]bool)
+	clients   = make(map[*websocket.Conn]string) // conn → role
 	clientsMu sync.Mutex
+
+	// Guarded by clientsMu.
+	lastBroadcast time.Time
+	lastWriteErr  string
+	lastErrAt     time.Time
+)
+
+// writeTimeout bounds each write, so one stuck client can't hold clientsMu
+// and stall every broadcast.
+const writeTimeout = 5 * time.Second
+
+var (
+	mClients    = metrics.NewGauge("overlay_ws_clients", "Connected WebSocket clients by role.", "role")
+	mBroadcasts = metrics.NewCounter("overlay_ws_broadcasts_total", "Broadcast messages by type.", "type")
+	mWriteFails = metrics.NewCounter("overlay_ws_write_failures_total", "WebSocket writes that failed and dropped the client.", "kind")
 )
 
 type WSMsg struct {
@@ -22,41 +40,149 @@ type WSMsg struct {
 	Data interface{} `json:"data,omitempty"`
 }
 
+// inbound is a message sent by a client; Data is decoded by its handler.
+type inbound struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// HandlerFunc processes one inbound message from a client with the given role.
+type HandlerFunc func(role string, data json.RawMessage)
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string]HandlerFunc{}
+
+	mInbound = metrics.NewCounter("overlay_ws_inbound_total", "Messages received from clients by type.", "type")
+)
+
+// Handle registers fn for inbound messages of msgType, replacing any
+// earlier handler. Messages with no handler are logged and dropped.
+func Handle(msgType string, fn HandlerFunc) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[msgType] = fn
+}
+
+func dispatch(role string, b []byte) {
+	var m inbound
+	if err := json.Unmarshal(b, &m); err != nil || m.Type == "" {
+		logger.Debug("ignoring malformed ws message", "role", role)
+		return
+	}
+	handlersMu.RLock()
+	fn := handlers[m.Type]
+	handlersMu.RUnlock()
+	if fn == nil {
+		logger.Debug("no handler for ws message", "role", role, "type", m.Type)
+		return
+	}
+	mInbound.Inc(m.Type)
+	fn(role, m.Data)
+}
+
 func Broadcast(m WSMsg) int {
 	b, _ := json.Marshal(m)
 	clientsMu.Lock()
 	defer clientsMu.Unlock()
 	n := 0
-	for c := range clients {
+	for c, role := range clients {
+		_ = c.SetWriteDeadline(time.Now().Add(writeTimeout))
 		if err := c.WriteMessage(websocket.TextMessage, b); err != nil {
-			log.Println("WS write error:", err)
+			logger.Warn("ws write failed, dropping client", "role", role, "err", err)
+			mWriteFails.Inc("message")
+			lastWriteErr, lastErrAt = err.Error(), time.Now()
+			mClients.Dec(role)
 			_ = c.Close()
 			delete(clients, c)
 		} else {
 			n++
 		}
 	}
-	log.Printf("Broadcast %q to %d client(s)", m.Type, n)
+	lastBroadcast = time.Now()
+	mBroadcasts.Inc(m.Type)
+	logger.Debug("broadcast", "type", m.Type, "clients", n)
 	return n
 }
 
+// HubStatus describes the broadcast hub for readiness checks.
+type HubStatus struct {
+	OK                bool           `json:"ok"`
+	Error             string         `json:"error,omitempty"`
+	Clients           map[string]int `json:"clients"`
+	LastBroadcastUnix int64          `json:"last_broadcast_unix,omitempty"`
+	LastWriteError    string         `json:"last_write_error,omitempty"`
+	LastWriteErrUnix  int64          `json:"last_write_error_unix,omitempty"`
+}
+
+// Status reports the hub's state. The hub is unhealthy when a broadcast
+// has held it for longer than wait, since nothing reaches overlays then.
+func Status(wait time.Duration) HubStatus {
+	deadline := time.Now().Add(wait)
+	for !clientsMu.TryLock() {
+		if time.Now().After(deadline) {
+			return HubStatus{Error: "broadcast hub is stuck", Clients: map[string]int{}}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer clientsMu.Unlock()
+	st := HubStatus{OK: true, Clients: map[string]int{}, LastWriteError: lastWriteErr}
+	for _, role := range clients {
+		st.Clients[role]++
+	}
+	if !lastBroadcast.IsZero() {
+		st.LastBroadcastUnix = lastBroadcast.Unix()
+	}
+	if !lastErrAt.IsZero() {
+		st.LastWriteErrUnix = lastErrAt.Unix()
+	}
+	return st
+}
+
+// ClientsByRole returns the number of connected clients per role.
+func ClientsByRole() map[string]int {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	out := map[string]int{}
+	for _, role := range clients {
+		out[role]++
+	}
+	return out
+}
+
 func ClientsCount() int {
 	clientsMu.Lock()
 	defer clientsMu.Unlock()
 	return len(clients)
 }
 
+// clientRole maps ?role= onto a known role. It labels a metric, so
+// anything unrecognised becomes "other".
+func clientRole(raw string) string {
+	switch raw {
+	case "", "overlay":
+		return "overlay"
+	case "panel":
+		return "panel"
+	}
+	return "other"
+}
+
+// WSHandler upgrades the connection and registers it for broadcasts.
+// Clients identify themselves with ?role= (overlay, the default, or panel).
 func WSHandler(w http.ResponseWriter, r *http.Request) {
+	role := clientRole(r.URL.Query().Get("role"))
 	c, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println("Upgrade:", err)
+		logger.WarnContext(r.Context(), "ws upgrade failed", "err", err)
 		return
 	}
 	clientsMu.Lock()
-	clients[c] = true
+	clients[c] = role
 	total := len(clients)
 	clientsMu.Unlock()
-	log.Printf("ws connected (%d total)", total)
+	mClients.Inc(role)
+	logger.InfoContext(r.Context(), "ws connected", "role", role, "total", total)
 
 	// keepalive pings
 	done := make(chan struct{})
@@ -68,7 +194,7 @@ func WSHandler(w http.ResponseWriter, r *http.Request) {
 			case <-t.C:
 				_ = c.SetWriteDeadline(time.Now().Add(10 * time.Second))
 				if err := c.WriteControl(websocket.PingMessage, []byte("ping"), time.Now().Add(10*time.Second)); err != nil {
-					close(done)
+					mWriteFails.Inc("ping")
 					return
 				}
 			case <-done:
@@ -85,15 +211,22 @@ func WSHandler(w http.ResponseWriter, r *http.Request) {
 		return nil
 	})
 	for {
-		if _, _, err := c.ReadMessage(); err != nil {
+		kind, b, err := c.ReadMessage()
+		if err != nil {
 			clientsMu.Lock()
-			delete(clients, c)
+			if _, ok := clients[c]; ok {
+				delete(clients, c)
+				mClients.Dec(role)
+			}
 			total = len(clients)
 			clientsMu.Unlock()
-			log.Printf("ws disconnected (%d total)", total)
+			logger.Info("ws disconnected", "role", role, "total", total)
 			_ = c.Close()
 			close(done)
 			return
 		}
+		if kind == websocket.TextMessage {
+			dispatch(role, b)
+		}
 	}
 }